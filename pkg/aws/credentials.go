@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package aws holds helpers shared by Cilium's AWS-backed subsystems (IPAM/ENI
+// allocation, clustermesh AWS integrations, ...) that do not belong to any one
+// of those subsystems specifically.
+package aws
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/metrics/metric"
+)
+
+// credentialSourceNames maps aws.CredentialSource to a short, stable label
+// suitable for use in logs and metrics. Unknown sources fall back to their
+// integer value so a future SDK addition doesn't get silently dropped.
+var credentialSourceNames = map[aws.CredentialSource]string{
+	aws.CredentialSourceCode:                 "code",
+	aws.CredentialSourceEnvVars:              "env_vars",
+	aws.CredentialSourceEnvVarsSTSWebIDToken: "env_vars_sts_web_id_token",
+	aws.CredentialSourceSTSAssumeRole:        "sts_assume_role",
+	aws.CredentialSourceSTSAssumeRoleSaml:    "sts_assume_role_saml",
+	aws.CredentialSourceSTSAssumeRoleWebID:   "sts_assume_role_web_id",
+	aws.CredentialSourceSTSFederationToken:   "sts_federation_token",
+	aws.CredentialSourceSTSSessionToken:      "sts_session_token",
+	aws.CredentialSourceProfile:              "profile",
+	aws.CredentialSourceProfileSourceProfile: "profile_source_profile",
+	aws.CredentialSourceProfileNamedProvider: "profile_named_provider",
+	aws.CredentialSourceProfileSTSWebIDToken: "profile_sts_web_id_token",
+	aws.CredentialSourceProfileSSO:           "profile_sso",
+	aws.CredentialSourceSSO:                  "sso",
+	aws.CredentialSourceProfileSSOLegacy:     "profile_sso_legacy",
+	aws.CredentialSourceSSOLegacy:            "sso_legacy",
+	aws.CredentialSourceProfileProcess:       "profile_process",
+	aws.CredentialSourceProcess:              "process",
+	aws.CredentialSourceHTTP:                 "http",
+	aws.CredentialSourceIMDS:                 "imds",
+}
+
+func credentialSourceName(s aws.CredentialSource) string {
+	if name, ok := credentialSourceNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+// CredentialChainGauge reports, as a set of 0/1 gauges labeled by source
+// kind, the provider chain walked by the last successful credential
+// Retrieve call. Looking at which label is set to 1 tells you at a glance
+// whether an agent ended up using IRSA, node-instance IMDS, a static
+// profile, or something else, without having to guess from failure modes.
+var CredentialChainGauge = metric.NewGaugeVec(metric.GaugeOpts{
+	Namespace: "cilium",
+	Subsystem: "aws",
+	Name:      "credential_chain_source",
+	Help:      "Whether the given credential source was part of the last successful AWS credential retrieval chain (1) or not (0)",
+}, []string{"source"})
+
+// credentialProviderSourceObserver wraps an aws.CredentialsProvider and
+// records the provider chain reported via the optional
+// aws.CredentialProviderSource interface after every successful Retrieve.
+type credentialProviderSourceObserver struct {
+	aws.CredentialsProvider
+	logger *slog.Logger
+
+	// reported is set once the chain has been logged at Info level, after
+	// which the chain of every later successful Retrieve, changed or not,
+	// is logged at Debug. The AWS SDK
+	// may call Retrieve concurrently from multiple goroutines refreshing
+	// credentials, so this is an atomic rather than a plain bool.
+	reported atomic.Bool
+}
+
+// ObserveCredentialProviderSources wraps provider so that, after each
+// successful Retrieve, the chain of CredentialSource values it reports via
+// the optional CredentialProviderSource interface is recorded into
+// CredentialChainGauge and logged once at startup and again on every
+// rotation. If provider does not implement CredentialProviderSource (e.g.
+// it is a static credentials provider), this is a no-op wrapper and
+// behavior is unchanged.
+//
+// Callers constructing the credentials provider chain for an AWS-backed
+// IPAM allocator (e.g. ENI) should wrap their terminal provider with this
+// before handing it to the allocator, so CredentialChainGauge reflects the
+// chain actually in use. No such construction site exists yet in this
+// tree; this wrapper is currently unused until one is added.
+func ObserveCredentialProviderSources(provider aws.CredentialsProvider, logger *slog.Logger) aws.CredentialsProvider {
+	if _, ok := provider.(aws.CredentialProviderSource); !ok {
+		return provider
+	}
+	return &credentialProviderSourceObserver{
+		CredentialsProvider: provider,
+		logger:              logger.With(logfields.LogSubsys, "aws-credentials"),
+	}
+}
+
+func (o *credentialProviderSourceObserver) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := o.CredentialsProvider.Retrieve(ctx)
+	if err != nil {
+		return creds, err
+	}
+
+	src, ok := o.CredentialsProvider.(aws.CredentialProviderSource)
+	if !ok {
+		return creds, nil
+	}
+
+	chain := src.ProviderSources()
+
+	CredentialChainGauge.Reset()
+	for _, name := range credentialSourceNames {
+		CredentialChainGauge.WithLabelValues(name).Set(0)
+	}
+	names := make([]string, 0, len(chain))
+	for _, s := range chain {
+		name := credentialSourceName(s)
+		names = append(names, name)
+		CredentialChainGauge.WithLabelValues(name).Set(1)
+	}
+
+	logFn := o.logger.Debug
+	if o.reported.CompareAndSwap(false, true) {
+		logFn = o.logger.Info
+	}
+	logFn("resolved AWS credentials provider chain",
+		logfields.Source, names,
+	)
+
+	return creds, nil
+}
@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialSourceName(t *testing.T) {
+	require.Equal(t, "imds", credentialSourceName(aws.CredentialSourceIMDS))
+	require.Equal(t, "sts_assume_role_web_id", credentialSourceName(aws.CredentialSourceSTSAssumeRoleWebID))
+
+	// An unrecognized source (e.g. one added by a future SDK version)
+	// falls back to its integer value rather than being dropped.
+	unknown := aws.CredentialSource(9999)
+	require.Equal(t, "9999", credentialSourceName(unknown))
+}
@@ -10,6 +10,7 @@ import (
 	"log/slog"
 	"maps"
 	"net/netip"
+	"slices"
 
 	"github.com/cilium/hive/cell"
 
@@ -21,7 +22,6 @@ import (
 	"github.com/cilium/cilium/pkg/k8s/resource"
 	"github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/labels"
 	slim_metav1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
-	"github.com/cilium/cilium/pkg/logging/logfields"
 )
 
 const (
@@ -38,35 +38,53 @@ type PodIPPoolReconcilerOut struct {
 type PodIPPoolReconcilerIn struct {
 	cell.In
 
-	Logger     *slog.Logger
-	PeerAdvert *CiliumPeerAdvertisement
-	PoolStore  store.BGPCPResourceStore[*v2alpha1.CiliumPodIPPool]
+	Logger       *slog.Logger
+	PeerAdvert   *CiliumPeerAdvertisement
+	PoolSource   IPPoolSource `optional:"true"`
+	NodeStore    store.BGPCPResourceStore[*v2.CiliumNode]
+	StatusClient CiliumPodIPPoolStatusClient `optional:"true"`
+	Health       cell.Health                 `optional:"true"`
 }
 
 type PodIPPoolReconciler struct {
-	logger     *slog.Logger
-	peerAdvert *CiliumPeerAdvertisement
-	poolStore  store.BGPCPResourceStore[*v2alpha1.CiliumPodIPPool]
-	metadata   map[string]PodIPPoolReconcilerMetadata
+	logger       *slog.Logger
+	peerAdvert   *CiliumPeerAdvertisement
+	poolSource   IPPoolSource
+	nodeStore    store.BGPCPResourceStore[*v2.CiliumNode]
+	statusWriter *PoolStatusWriter
+	health       cell.Health
+	healthScopes map[resource.Key]cell.Health
+	metadata     map[string]PodIPPoolReconcilerMetadata
 }
 
 // PodIPPoolReconcilerMetadata holds any announced pod ip pool CIDRs keyed by pool name of the backing CiliumPodIPPool.
 type PodIPPoolReconcilerMetadata struct {
 	PoolAFPaths       ResourceAFPathsMap
 	PoolRoutePolicies ResourceRoutePolicyMap
+
+	// AggregateState tracks aggregate-announcer election/hysteresis state
+	// per pool, keyed the same way as PoolAFPaths/PoolRoutePolicies. Only
+	// populated for pools with at least one Summary/PoolCIDR advertisement.
+	AggregateState map[resource.Key]*aggregateAnnouncerState
 }
 
 func NewPodIPPoolReconciler(in PodIPPoolReconcilerIn) PodIPPoolReconcilerOut {
-	if in.PoolStore == nil {
+	if in.PoolSource == nil {
 		return PodIPPoolReconcilerOut{}
 	}
 
+	logger := in.Logger.With(types.ReconcilerLogField, "PodIPPool")
+
 	return PodIPPoolReconcilerOut{
 		Reconciler: &PodIPPoolReconciler{
-			logger:     in.Logger.With(types.ReconcilerLogField, "PodIPPool"),
-			peerAdvert: in.PeerAdvert,
-			poolStore:  in.PoolStore,
-			metadata:   make(map[string]PodIPPoolReconcilerMetadata),
+			logger:       logger,
+			peerAdvert:   in.PeerAdvert,
+			poolSource:   in.PoolSource,
+			nodeStore:    in.NodeStore,
+			statusWriter: NewPoolStatusWriter(logger, in.StatusClient),
+			health:       in.Health,
+			healthScopes: make(map[resource.Key]cell.Health),
+			metadata:     make(map[string]PodIPPoolReconcilerMetadata),
 		},
 	}
 }
@@ -86,6 +104,7 @@ func (r *PodIPPoolReconciler) Init(i *instance.BGPInstance) error {
 	r.metadata[i.Name] = PodIPPoolReconcilerMetadata{
 		PoolAFPaths:       make(ResourceAFPathsMap),
 		PoolRoutePolicies: make(ResourceRoutePolicyMap),
+		AggregateState:    make(map[resource.Key]*aggregateAnnouncerState),
 	}
 	return nil
 }
@@ -108,12 +127,14 @@ func (r *PodIPPoolReconciler) Reconcile(ctx context.Context, p ReconcileParams)
 		return err
 	}
 
-	err = r.reconcileRoutePolicies(ctx, p, desiredPeerAdverts, lp)
-	if err != nil {
-		return err
+	reconcileErr := r.reconcileRoutePolicies(ctx, p, desiredPeerAdverts, lp)
+	if reconcileErr == nil {
+		reconcileErr = r.reconcilePaths(ctx, p, desiredPeerAdverts, lp)
 	}
 
-	return r.reconcilePaths(ctx, p, desiredPeerAdverts, lp)
+	r.reportPoolStatuses(ctx, p, desiredPeerAdverts, lp, reconcileErr)
+
+	return reconcileErr
 }
 
 func (r *PodIPPoolReconciler) reconcilePaths(ctx context.Context, p ReconcileParams, desiredPeerAdverts PeerAdvertisements, lp map[string][]netip.Prefix) error {
@@ -141,32 +162,25 @@ func (r *PodIPPoolReconciler) getDesiredPoolAFPaths(p ReconcileParams, desiredFa
 
 	metadata := r.getMetadata(p.BGPInstance)
 
-	// check if any pool is deleted
-	for poolKey := range metadata.PoolAFPaths {
-		_, exists, err := r.poolStore.GetByKey(poolKey)
-		if err != nil {
-			if errors.Is(err, store.ErrStoreUninitialized) {
-				err = errors.Join(err, ErrAbortReconcile)
-			}
-			return nil, err
-		}
-
-		if !exists {
-			// pool is deleted, mark it for removal
-			desiredPoolAFPaths[poolKey] = nil
-		}
-	}
-
-	pools, err := r.poolStore.List()
+	pools, err := r.poolSource.Pools()
 	if err != nil {
 		if errors.Is(err, store.ErrStoreUninitialized) {
 			err = errors.Join(err, ErrAbortReconcile)
 		}
 		return nil, err
 	}
+	byKey := poolsByKey(pools)
+
+	// check if any pool is deleted
+	for poolKey := range metadata.PoolAFPaths {
+		if _, exists := byKey[poolKey]; !exists {
+			// pool is deleted, mark it for removal
+			desiredPoolAFPaths[poolKey] = nil
+		}
+	}
 
 	for _, pool := range pools {
-		desiredPaths, err := r.getDesiredAFPaths(pool, desiredFamilyAdverts, lp)
+		desiredPaths, err := r.getDesiredAFPaths(p, pool, desiredFamilyAdverts, lp)
 		if err != nil {
 			return nil, err
 		}
@@ -223,25 +237,21 @@ func (r *PodIPPoolReconciler) getDesiredPodIPPoolRoutePolicies(p ReconcileParams
 
 	desiredPodIPPoolRoutePolicies := make(ResourceRoutePolicyMap)
 
+	// get all pools and their route policies
+	pools, err := r.poolSource.Pools()
+	if err != nil {
+		return nil, err
+	}
+	byKey := poolsByKey(pools)
+
 	// mark for deleting pool policies
 	for poolKey := range metadata.PoolRoutePolicies {
-		_, exists, err := r.poolStore.GetByKey(poolKey)
-		if err != nil {
-			return nil, err
-		}
-
-		if !exists {
+		if _, exists := byKey[poolKey]; !exists {
 			// pool is deleted, mark it for removal
 			desiredPodIPPoolRoutePolicies[poolKey] = nil
 		}
 	}
 
-	// get all pools and their route policies
-	pools, err := r.poolStore.List()
-	if err != nil {
-		return nil, err
-	}
-
 	for _, pool := range pools {
 		desiredPoolRoutePolicies, err := r.getPodIPPoolPolicies(p, pool, desiredPeerAdverts, lp)
 		if err != nil {
@@ -265,7 +275,7 @@ func (r *PodIPPoolReconciler) getPodIPPoolPolicies(p ReconcileParams, pool *v2al
 		for family, adverts := range afAdverts {
 			fam := types.ToAgentFamily(family)
 			for _, advert := range adverts {
-				policy, err := r.getPodIPPoolPolicy(peer, fam, pool, advert, lp)
+				policy, err := r.getPodIPPoolPolicy(p, peer, fam, pool, advert, lp)
 				if err != nil {
 					return nil, err
 				}
@@ -279,34 +289,14 @@ func (r *PodIPPoolReconciler) getPodIPPoolPolicies(p ReconcileParams, pool *v2al
 	return desiredRoutePolicies, nil
 }
 
-// populateLocalPools returns a map of allocated multi-pool IPAM CIDRs of the local CiliumNode,
-// keyed by the pool name.
+// populateLocalPools returns a map of allocated IPAM CIDRs of the local
+// CiliumNode, keyed by the pool name, as resolved by the reconciler's
+// configured IPPoolSource.
 func (r *PodIPPoolReconciler) populateLocalPools(localNode *v2.CiliumNode) map[string][]netip.Prefix {
-	if localNode == nil {
-		return nil
-	}
-
-	lp := make(map[string][]netip.Prefix)
-	for _, pool := range localNode.Spec.IPAM.Pools.Allocated {
-		var prefixes []netip.Prefix
-		for _, cidr := range pool.CIDRs {
-			if p, err := cidr.ToPrefix(); err == nil {
-				prefixes = append(prefixes, *p)
-			} else {
-				r.logger.Error(
-					"invalid IPAM pool CIDR",
-					logfields.Error, err,
-					types.PrefixLogField, cidr,
-				)
-			}
-		}
-		lp[pool.Pool] = prefixes
-	}
-
-	return lp
+	return r.poolSource.LocalAllocations(localNode)
 }
 
-func (r *PodIPPoolReconciler) getDesiredAFPaths(pool *v2alpha1.CiliumPodIPPool, desiredPeerAdverts PeerAdvertisements, lp map[string][]netip.Prefix) (AFPathsMap, error) {
+func (r *PodIPPoolReconciler) getDesiredAFPaths(p ReconcileParams, pool *v2alpha1.CiliumPodIPPool, desiredPeerAdverts PeerAdvertisements, lp map[string][]netip.Prefix) (AFPathsMap, error) {
 	// Calculate desired paths per address family, collapsing per-peer advertisements into per-family advertisements.
 	desiredFamilyAdverts := make(AFPathsMap)
 
@@ -331,11 +321,31 @@ func (r *PodIPPoolReconciler) getDesiredAFPaths(pool *v2alpha1.CiliumPodIPPool,
 				}
 
 				// Ignore non matching pool.
-				if !poolSelector.Matches(podIPPoolLabelSet(pool)) {
+				if !poolSelector.Matches(podIPPoolLabelSet(pool)) || !podIPPoolNamespaceMatches(pool, advert) {
 					continue
 				}
 
-				if prefixes, exists := lp[pool.Name]; exists {
+				prefixes, hasLocalAllocation := lp[pool.Name]
+
+				if advert.AggregateMode == v2.BGPAggregationModeSummary || advert.AggregateMode == v2.BGPAggregationModePoolCIDR {
+					if !r.shouldAnnounceAggregate(p, pool, hasLocalAllocation && len(prefixes) > 0) {
+						continue
+					}
+					for _, prefix := range aggregatePrefixes(pool) {
+						path := types.NewPathForPrefix(prefix)
+						path.Family = agentFamily
+
+						if agentFamily.Afi == types.AfiIPv4 && prefix.Addr().Is4() {
+							addPathToAFPathsMap(desiredFamilyAdverts, agentFamily, path)
+						}
+						if agentFamily.Afi == types.AfiIPv6 && prefix.Addr().Is6() {
+							addPathToAFPathsMap(desiredFamilyAdverts, agentFamily, path)
+						}
+					}
+					continue
+				}
+
+				if hasLocalAllocation {
 					// on the local node we have this pool configured.
 					// add the prefixes to the desiredPaths.
 					for _, prefix := range prefixes {
@@ -358,7 +368,7 @@ func (r *PodIPPoolReconciler) getDesiredAFPaths(pool *v2alpha1.CiliumPodIPPool,
 	return desiredFamilyAdverts, nil
 }
 
-func (r *PodIPPoolReconciler) getPodIPPoolPolicy(peer PeerID, family types.Family, pool *v2alpha1.CiliumPodIPPool, advert v2.BGPAdvertisement, lp map[string][]netip.Prefix) (*types.RoutePolicy, error) {
+func (r *PodIPPoolReconciler) getPodIPPoolPolicy(p ReconcileParams, peer PeerID, family types.Family, pool *v2alpha1.CiliumPodIPPool, advert v2.BGPAdvertisement, lp map[string][]netip.Prefix) (*types.RoutePolicy, error) {
 	if peer.Address == "" {
 		return nil, nil
 	}
@@ -374,35 +384,65 @@ func (r *PodIPPoolReconciler) getPodIPPoolPolicy(peer PeerID, family types.Famil
 	}
 
 	// Ignore non matching pool.
-	if !poolSelector.Matches(podIPPoolLabelSet(pool)) {
-		return nil, nil
-	}
-
-	// only include pool cidrs that have been allocated to the local node.
-	prefixes, exists := lp[pool.Name]
-	if !exists {
+	if !poolSelector.Matches(podIPPoolLabelSet(pool)) || !podIPPoolNamespaceMatches(pool, advert) {
 		return nil, nil
 	}
 
 	var v4Prefixes, v6Prefixes types.PolicyPrefixMatchList
 
-	for _, prefix := range prefixes {
-		if family.Afi == types.AfiIPv4 && prefix.Addr().Is4() {
-			prefixLen := int(pool.Spec.IPv4.MaskSize)
-			v4Prefixes = append(v4Prefixes, &types.RoutePolicyPrefixMatch{
-				CIDR:         prefix,
-				PrefixLenMin: prefixLen,
-				PrefixLenMax: prefixLen,
-			})
+	if advert.AggregateMode == v2.BGPAggregationModeSummary || advert.AggregateMode == v2.BGPAggregationModePoolCIDR {
+		prefixes, hasLocalAllocation := lp[pool.Name]
+		if !r.shouldAnnounceAggregate(p, pool, hasLocalAllocation && len(prefixes) > 0) {
+			return nil, nil
+		}
+		for _, prefix := range aggregatePrefixes(pool) {
+			if family.Afi == types.AfiIPv4 && prefix.Addr().Is4() {
+				v4Prefixes = append(v4Prefixes, &types.RoutePolicyPrefixMatch{
+					CIDR:         prefix,
+					PrefixLenMin: prefix.Bits(),
+					PrefixLenMax: prefix.Bits(),
+				})
+			}
+			if family.Afi == types.AfiIPv6 && prefix.Addr().Is6() {
+				v6Prefixes = append(v6Prefixes, &types.RoutePolicyPrefixMatch{
+					CIDR:         prefix,
+					PrefixLenMin: prefix.Bits(),
+					PrefixLenMax: prefix.Bits(),
+				})
+			}
+		}
+	} else {
+		// only include pool cidrs that have been allocated to the local node.
+		prefixes, exists := lp[pool.Name]
+		if !exists {
+			return nil, nil
 		}
 
-		if family.Afi == types.AfiIPv6 && prefix.Addr().Is6() {
-			prefixLen := int(pool.Spec.IPv6.MaskSize)
-			v6Prefixes = append(v6Prefixes, &types.RoutePolicyPrefixMatch{
-				CIDR:         prefix,
-				PrefixLenMin: prefixLen,
-				PrefixLenMax: prefixLen,
-			})
+		for _, prefix := range prefixes {
+			if family.Afi == types.AfiIPv4 && prefix.Addr().Is4() {
+				prefixLen := int(pool.Spec.IPv4.MaskSize)
+				if prefixLen == 0 {
+					// synthetic pools carry no mask size of their own
+					prefixLen = prefix.Bits()
+				}
+				v4Prefixes = append(v4Prefixes, &types.RoutePolicyPrefixMatch{
+					CIDR:         prefix,
+					PrefixLenMin: prefixLen,
+					PrefixLenMax: prefixLen,
+				})
+			}
+
+			if family.Afi == types.AfiIPv6 && prefix.Addr().Is6() {
+				prefixLen := int(pool.Spec.IPv6.MaskSize)
+				if prefixLen == 0 {
+					prefixLen = prefix.Bits()
+				}
+				v6Prefixes = append(v6Prefixes, &types.RoutePolicyPrefixMatch{
+					CIDR:         prefix,
+					PrefixLenMin: prefixLen,
+					PrefixLenMax: prefixLen,
+				})
+			}
 		}
 	}
 
@@ -411,10 +451,24 @@ func (r *PodIPPoolReconciler) getPodIPPoolPolicy(peer PeerID, family types.Famil
 		return nil, nil
 	}
 
-	policyName := PolicyName(peer.Name, family.Afi.String(), advert.AdvertisementType, pool.Name)
+	// Namespace is included so that two CiliumPodIPPools with the same
+	// name in different namespaces generate distinct policy names and
+	// can both be advertised to the same peer concurrently.
+	policyName := PolicyName(peer.Name, family.Afi.String(), advert.AdvertisementType, podIPPoolPolicyResourceName(pool))
 	return CreatePolicy(policyName, peerAddr, v4Prefixes, v6Prefixes, advert)
 }
 
+// poolsByKey indexes pools by their resource.Key for existence checks,
+// since IPPoolSource.Pools may return synthetic pools that have no
+// backing resource store to query by key.
+func poolsByKey(pools []*v2alpha1.CiliumPodIPPool) map[resource.Key]*v2alpha1.CiliumPodIPPool {
+	byKey := make(map[resource.Key]*v2alpha1.CiliumPodIPPool, len(pools))
+	for _, pool := range pools {
+		byKey[resource.Key{Name: pool.Name, Namespace: pool.Namespace}] = pool
+	}
+	return byKey
+}
+
 func podIPPoolLabelSet(pool *v2alpha1.CiliumPodIPPool) labels.Labels {
 	poolLabels := maps.Clone(pool.Labels)
 	if poolLabels == nil {
@@ -425,6 +479,29 @@ func podIPPoolLabelSet(pool *v2alpha1.CiliumPodIPPool) labels.Labels {
 	return labels.Set(poolLabels)
 }
 
+// podIPPoolNamespaceMatches reports whether pool is eligible for advert's
+// namespace scoping. CiliumPodIPPool is cluster-scoped, so this matches
+// against Spec.Namespace - a logical grouping independent of the resource's
+// own scope - rather than the object's (always-empty) metadata namespace.
+// An advertisement with no Namespaces filter configured matches pools
+// regardless of Spec.Namespace, preserving pre-namespacing behavior.
+func podIPPoolNamespaceMatches(pool *v2alpha1.CiliumPodIPPool, advert v2.BGPAdvertisement) bool {
+	if len(advert.Namespaces) == 0 {
+		return true
+	}
+	return slices.Contains(advert.Namespaces, pool.Spec.Namespace)
+}
+
+// podIPPoolPolicyResourceName returns the name component used to build a
+// per-pool route policy name. It includes the namespace so that two pools
+// sharing a name in different namespaces produce distinct policies.
+func podIPPoolPolicyResourceName(pool *v2alpha1.CiliumPodIPPool) string {
+	if pool.Namespace == "" {
+		return pool.Name
+	}
+	return pool.Namespace + "/" + pool.Name
+}
+
 func (r *PodIPPoolReconciler) getMetadata(i *instance.BGPInstance) PodIPPoolReconcilerMetadata {
 	return r.metadata[i.Name]
 }
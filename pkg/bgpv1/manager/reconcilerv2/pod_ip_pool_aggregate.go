@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package reconcilerv2
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+
+	v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2alpha1"
+	"github.com/cilium/cilium/pkg/k8s/resource"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/metrics/metric"
+)
+
+// AggregateAnnouncerGauge reports, per pool, whether the local node is
+// currently the elected announcer of that pool's aggregate/summary CIDR.
+// Exactly one node in the cluster should read 1 for a given pool at a time;
+// operators can use this to spot a stuck election (zero or multiple 1s).
+var AggregateAnnouncerGauge = metric.NewGaugeVec(metric.GaugeOpts{
+	Namespace: "cilium",
+	Subsystem: "bgp_control_plane",
+	Name:      "pod_ip_pool_aggregate_announcer",
+	Help:      "Whether the local node is the elected announcer of a pod IP pool's aggregate CIDR (1) or not (0)",
+}, []string{"pool"})
+
+// aggregateWithdrawalDelay is how long a node keeps announcing an aggregate
+// it was elected to announce after it locally loses its last sub-allocation
+// from the pool, so that a brief allocation blip doesn't flap the route.
+const aggregateWithdrawalDelay = 10 * time.Second
+
+// aggregateAnnouncerState tracks, per pool, which node is currently elected
+// to announce the pool's aggregate/summary CIDR, and since when the local
+// node has been without a qualifying sub-allocation (for the withdrawal
+// hysteresis timer).
+type aggregateAnnouncerState struct {
+	// electedNode is the node currently elected to announce the
+	// aggregate for this pool, or empty if no node currently qualifies.
+	electedNode string
+	// localLostAt is when the local node was first observed without a
+	// sub-allocation for this pool while it was the elected announcer. A
+	// zero value means the local node currently holds an allocation (or
+	// was never elected).
+	localLostAt time.Time
+}
+
+// aggregatePrefixes returns the configured parent CIDR(s) for pool - the
+// prefixes a Summary/PoolCIDR advertisement announces in place of the
+// per-node sub-allocations.
+func aggregatePrefixes(pool *v2alpha1.CiliumPodIPPool) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, cidr := range pool.Spec.IPv4.CIDRs {
+		if p, err := cidr.ToPrefix(); err == nil {
+			prefixes = append(prefixes, *p)
+		}
+	}
+	for _, cidr := range pool.Spec.IPv6.CIDRs {
+		if p, err := cidr.ToPrefix(); err == nil {
+			prefixes = append(prefixes, *p)
+		}
+	}
+	return prefixes
+}
+
+// electAnnouncer deterministically picks a single announcer, among the
+// nodes that currently hold at least one sub-allocation from the pool, by
+// lexicographically smallest node name. Using node name rather than e.g.
+// first-seen avoids the elected node changing on every reconcile simply due
+// to map iteration order.
+func electAnnouncer(holders []string) string {
+	if len(holders) == 0 {
+		return ""
+	}
+	sorted := append([]string(nil), holders...)
+	sort.Strings(sorted)
+	return sorted[0]
+}
+
+// poolHolders returns the names of every node that currently has at least
+// one allocated prefix from pool, as reported by CiliumNode.Spec.IPAM. It
+// always includes localNodeName if localHasAllocation is set, so the local
+// node's own (possibly stale, between watch events) view of its allocation
+// takes priority over the cached peer store.
+func poolHolders(nodes []*v2.CiliumNode, poolName string, localNodeName string, localHasAllocation bool) []string {
+	holders := make(map[string]struct{})
+
+	for _, n := range nodes {
+		if n.Name == localNodeName {
+			continue
+		}
+		for _, alloc := range n.Spec.IPAM.Pools.Allocated {
+			if alloc.Pool != poolName {
+				continue
+			}
+			if len(alloc.CIDRs) > 0 {
+				holders[n.Name] = struct{}{}
+			}
+		}
+	}
+
+	if localHasAllocation {
+		holders[localNodeName] = struct{}{}
+	}
+
+	names := make([]string, 0, len(holders))
+	for name := range holders {
+		names = append(names, name)
+	}
+	return names
+}
+
+// resolveAggregateAnnouncer updates state for pool with the current set of
+// holders and returns whether the local node should announce the aggregate
+// this reconcile. Election changes are subject to aggregateWithdrawalDelay
+// hysteresis on the local node's side: once elected, the local node keeps
+// announcing for aggregateWithdrawalDelay after it stops holding an
+// allocation, rather than flapping the route immediately.
+func resolveAggregateAnnouncer(state *aggregateAnnouncerState, holders []string, localNodeName string, localHasAllocation bool, now time.Time) bool {
+	elected := electAnnouncer(holders)
+
+	if elected == localNodeName {
+		state.electedNode = localNodeName
+		state.localLostAt = time.Time{}
+		return true
+	}
+
+	// The local node is not the freshly elected announcer. If it was
+	// announcing up until now and has lost its allocation, apply the
+	// withdrawal hysteresis before handing over to the newly elected node.
+	// If it still holds an allocation, another holder sorts ahead of it
+	// and it hands over right away.
+	if state.electedNode == localNodeName && !localHasAllocation {
+		if state.localLostAt.IsZero() {
+			state.localLostAt = now
+		}
+		if now.Sub(state.localLostAt) < aggregateWithdrawalDelay {
+			return true
+		}
+	}
+
+	state.electedNode = elected
+	state.localLostAt = time.Time{}
+	return false
+}
+
+// shouldAnnounceAggregate reports whether the local node should announce
+// pool's aggregate CIDR this reconcile, running the election/hysteresis
+// logic above and persisting its result in the instance's metadata so it
+// carries over between reconciles. hasLocalAllocation indicates whether the
+// local node currently holds at least one sub-allocation from pool.
+func (r *PodIPPoolReconciler) shouldAnnounceAggregate(p ReconcileParams, pool *v2alpha1.CiliumPodIPPool, hasLocalAllocation bool) bool {
+	key := resource.Key{Name: pool.Name, Namespace: pool.Namespace}
+
+	metadata := r.getMetadata(p.BGPInstance)
+	state, exists := metadata.AggregateState[key]
+	if !exists {
+		state = &aggregateAnnouncerState{}
+		metadata.AggregateState[key] = state
+	}
+
+	var nodes []*v2.CiliumNode
+	if r.nodeStore != nil {
+		var err error
+		nodes, err = r.nodeStore.List()
+		if err != nil {
+			r.logger.Error("failed to list CiliumNodes for aggregate election, keeping previous election", logfields.Error, err)
+			return state.electedNode != "" && p.CiliumNode != nil && state.electedNode == p.CiliumNode.Name
+		}
+	}
+
+	localNodeName := ""
+	if p.CiliumNode != nil {
+		localNodeName = p.CiliumNode.Name
+	}
+
+	holders := poolHolders(nodes, pool.Name, localNodeName, hasLocalAllocation)
+	announce := resolveAggregateAnnouncer(state, holders, localNodeName, hasLocalAllocation, time.Now())
+
+	r.setMetadata(p.BGPInstance, metadata)
+
+	if announce {
+		AggregateAnnouncerGauge.WithLabelValues(key.String()).Set(1)
+	} else {
+		AggregateAnnouncerGauge.WithLabelValues(key.String()).Set(0)
+	}
+
+	return announce
+}
+
+// isAnnouncingAggregate reports whether the local node is currently the
+// elected announcer of pool's aggregate CIDR, without running election or
+// touching hysteresis state. It is a read-only counterpart to
+// shouldAnnounceAggregate for status-reporting callers, which must not
+// advance the withdrawal timer or re-elect an announcer outside of
+// Reconcile. If no election has run yet for pool, it reports false.
+func (r *PodIPPoolReconciler) isAnnouncingAggregate(p ReconcileParams, pool *v2alpha1.CiliumPodIPPool) bool {
+	key := resource.Key{Name: pool.Name, Namespace: pool.Namespace}
+
+	metadata := r.getMetadata(p.BGPInstance)
+	state, exists := metadata.AggregateState[key]
+	if !exists {
+		return false
+	}
+
+	localNodeName := ""
+	if p.CiliumNode != nil {
+		localNodeName = p.CiliumNode.Name
+	}
+
+	return state.electedNode != "" && state.electedNode == localNodeName
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package reconcilerv2
+
+import (
+	"log/slog"
+	"net/netip"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cilium/cilium/pkg/bgpv1/manager/store"
+	v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2alpha1"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+)
+
+// virtualPoolName names the synthetic CiliumPodIPPool ClusterPoolSource
+// wraps the agent's classic (non-CRD) pod CIDRs in. It is not a real
+// CiliumPodIPPool resource and is never persisted.
+const virtualPoolName = "cilium-cluster-pool"
+
+// IPPoolSource resolves the set of pod IP pools the BGP control plane may
+// advertise and which of their CIDRs are allocated to the local node,
+// abstracting PodIPPoolReconciler away from the agent's configured IPAM
+// mode. Implementations exist for CRD-backed pools (multi-pool IPAM, and
+// any other CRD-driven external IPAM that publishes CiliumPodIPPools) and
+// for the classic cluster-pool/host-scope IPAM mode, which has no
+// CiliumPodIPPool resources of its own.
+type IPPoolSource interface {
+	// Pools returns every pool that may be advertised, real or synthetic.
+	Pools() ([]*v2alpha1.CiliumPodIPPool, error)
+	// LocalAllocations returns the prefixes allocated to localNode, keyed
+	// by pool name.
+	LocalAllocations(localNode *v2.CiliumNode) map[string][]netip.Prefix
+}
+
+// CRDPoolSource is an IPPoolSource backed by a CiliumPodIPPool resource
+// store. It covers multi-pool IPAM, as well as any CRD-driven external
+// IPAM that publishes pools in the CiliumPodIPPool shape, and reproduces
+// the reconciler's pre-IPPoolSource behavior unchanged.
+type CRDPoolSource struct {
+	poolStore store.BGPCPResourceStore[*v2alpha1.CiliumPodIPPool]
+	logger    *slog.Logger
+}
+
+// NewCRDPoolSource returns an IPPoolSource that lists pools from poolStore.
+func NewCRDPoolSource(poolStore store.BGPCPResourceStore[*v2alpha1.CiliumPodIPPool], logger *slog.Logger) *CRDPoolSource {
+	return &CRDPoolSource{poolStore: poolStore, logger: logger}
+}
+
+func (s *CRDPoolSource) Pools() ([]*v2alpha1.CiliumPodIPPool, error) {
+	if s.poolStore == nil {
+		return nil, nil
+	}
+	return s.poolStore.List()
+}
+
+// LocalAllocations returns the multi-pool IPAM allocations of localNode,
+// keyed by pool name.
+func (s *CRDPoolSource) LocalAllocations(localNode *v2.CiliumNode) map[string][]netip.Prefix {
+	if localNode == nil {
+		return nil
+	}
+
+	lp := make(map[string][]netip.Prefix)
+	for _, pool := range localNode.Spec.IPAM.Pools.Allocated {
+		var prefixes []netip.Prefix
+		for _, cidr := range pool.CIDRs {
+			if p, err := cidr.ToPrefix(); err == nil {
+				prefixes = append(prefixes, *p)
+			} else {
+				s.logger.Error(
+					"invalid IPAM pool CIDR",
+					logfields.Error, err,
+				)
+			}
+		}
+		lp[pool.Pool] = prefixes
+	}
+
+	return lp
+}
+
+// ClusterPoolSource is an IPPoolSource for the classic cluster-pool and
+// host-scope IPAM modes, where the agent is allocated pod CIDRs directly on
+// CiliumNode.Spec/Status.IPAM rather than through CiliumPodIPPool
+// resources. It wraps those CIDRs in a single synthetic pool so the
+// existing CiliumPodIPPool-shaped advertisement and selector plumbing can
+// be reused unchanged.
+type ClusterPoolSource struct{}
+
+// NewClusterPoolSource returns an IPPoolSource for cluster-pool/host-scope
+// IPAM.
+func NewClusterPoolSource() *ClusterPoolSource {
+	return &ClusterPoolSource{}
+}
+
+func (s *ClusterPoolSource) Pools() ([]*v2alpha1.CiliumPodIPPool, error) {
+	return []*v2alpha1.CiliumPodIPPool{virtualPool()}, nil
+}
+
+// LocalAllocations returns localNode's pod CIDRs (preferring the allocated
+// Status CIDRs, falling back to the requested Spec CIDRs) under the single
+// virtual pool name.
+func (s *ClusterPoolSource) LocalAllocations(localNode *v2.CiliumNode) map[string][]netip.Prefix {
+	if localNode == nil {
+		return nil
+	}
+
+	cidrs := localNode.Status.IPAM.PodCIDRs
+	if len(cidrs) == 0 {
+		cidrs = localNode.Spec.IPAM.PodCIDRs
+	}
+
+	var prefixes []netip.Prefix
+	for _, cidr := range cidrs {
+		if p, err := netip.ParsePrefix(cidr); err == nil {
+			prefixes = append(prefixes, p)
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	return map[string][]netip.Prefix{virtualPoolName: prefixes}
+}
+
+// virtualPool returns the synthetic, unnamespaced CiliumPodIPPool
+// ClusterPoolSource advertises under. Its CIDRs are populated per-node by
+// LocalAllocations; it carries no IPv4/IPv6 CIDR spec of its own since
+// cluster-pool mode has no single cluster-wide pool definition to mirror.
+func virtualPool() *v2alpha1.CiliumPodIPPool {
+	return &v2alpha1.CiliumPodIPPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: virtualPoolName,
+		},
+	}
+}
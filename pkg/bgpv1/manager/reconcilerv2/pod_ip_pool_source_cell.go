@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package reconcilerv2
+
+import (
+	"log/slog"
+
+	"github.com/cilium/hive/cell"
+
+	"github.com/cilium/cilium/pkg/bgpv1/manager/store"
+	ipamOption "github.com/cilium/cilium/pkg/ipam/option"
+	"github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2alpha1"
+)
+
+// Cell provides the IPPoolSource PodIPPoolReconciler needs to resolve pod IP
+// pools and their per-node allocations, independent of the agent's
+// configured IPAM mode.
+var Cell = cell.Module(
+	"bgp-pod-ip-pool-source",
+	"BGP Pod IP Pool Source",
+
+	cell.Provide(NewIPPoolSource),
+)
+
+// IPPoolSourceIn are the dependencies needed to select an IPPoolSource
+// matching the agent's configured IPAM mode.
+type IPPoolSourceIn struct {
+	cell.In
+
+	Logger    *slog.Logger
+	IPAMMode  ipamOption.Config
+	PoolStore store.BGPCPResourceStore[*v2alpha1.CiliumPodIPPool] `optional:"true"`
+}
+
+// NewIPPoolSource returns the IPPoolSource matching the agent's configured
+// IPAM mode: ClusterPoolSource for the classic cluster-pool and host-scope
+// (kubernetes) modes, which read allocations off Status.IPAM.PodCIDRs
+// (or Spec.IPAM.PodCIDRs while the status is empty), and CRDPoolSource for every other mode - multi-pool
+// IPAM and any CRD-driven external IPAM (ENI, Azure, AlibabaCloud, ...)
+// that publishes CiliumPodIPPools. Without this, PodIPPoolReconcilerIn.PoolSource
+// has no provider and the reconciler is silently disabled.
+func NewIPPoolSource(in IPPoolSourceIn) IPPoolSource {
+	switch in.IPAMMode.IPAMMode() {
+	case ipamOption.IPAMClusterPool, ipamOption.IPAMKubernetes:
+		return NewClusterPoolSource()
+	default:
+		return NewCRDPoolSource(in.PoolStore, in.Logger)
+	}
+}
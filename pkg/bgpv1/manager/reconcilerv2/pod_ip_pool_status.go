@@ -0,0 +1,404 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package reconcilerv2
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cilium/cilium/pkg/bgpv1/types"
+	v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2alpha1"
+	"github.com/cilium/cilium/pkg/k8s/resource"
+	slim_metav1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+)
+
+// minStatusWriteInterval rate-limits CiliumPodIPPool.Status.BGP writes per
+// pool, so a hot reconcile loop (e.g. while a peer is flapping) doesn't
+// hammer the API server. Updates that arrive inside the window are
+// coalesced: only the latest is kept, and it is written on a later Flush.
+const minStatusWriteInterval = 2 * time.Second
+
+// Conditions reported on CiliumPodIPPool.Status.BGP, following the standard
+// Kubernetes meta/v1 Condition pattern.
+const (
+	ConditionAdvertised  = "Advertised"
+	ConditionSelected    = "Selected"
+	ConditionStoreSynced = "StoreSynced"
+)
+
+// CiliumPodIPPoolStatusClient updates the Status.BGP subresource of a
+// CiliumPodIPPool. Satisfied by the generated CiliumV2alpha1 clientset; kept
+// as a narrow interface here so PoolStatusWriter doesn't need the full
+// clientset surface, mirroring how this package already narrows BGP peer
+// and resource-store dependencies to just the methods it uses.
+type CiliumPodIPPoolStatusClient interface {
+	UpdateStatus(ctx context.Context, pool *v2alpha1.CiliumPodIPPool, opts metav1.UpdateOptions) (*v2alpha1.CiliumPodIPPool, error)
+}
+
+// PoolPeerStatus describes one peer currently advertising a pool.
+type PoolPeerStatus struct {
+	PeerName string
+	// Prefixes maps AFI ("ipv4"/"ipv6") to the prefixes announced to this
+	// peer for this pool.
+	Prefixes map[string][]string
+	// RoutePolicyNames are the names of the route policies elected for
+	// this peer and pool.
+	RoutePolicyNames []string
+}
+
+// PoolStatusWriter maintains and publishes the CiliumPodIPPool.Status.BGP
+// subresource: the peers currently advertising each pool, the last
+// reconcile error, and the pool's Advertised/Selected/StoreSynced
+// conditions. Writes are coalesced and rate-limited per pool so a flapping
+// pool doesn't generate a write on every reconcile.
+type PoolStatusWriter struct {
+	logger *slog.Logger
+	client CiliumPodIPPoolStatusClient
+
+	mu        lock.Mutex
+	lastWrite map[resource.Key]time.Time
+	pending   map[resource.Key]*v2alpha1.CiliumPodIPPool
+}
+
+// NewPoolStatusWriter returns a PoolStatusWriter that publishes status
+// updates through client. client may be nil, in which case Update and
+// Flush are no-ops; this lets PodIPPoolReconciler be constructed without a
+// status client (e.g. in tests) without special-casing every call site.
+func NewPoolStatusWriter(logger *slog.Logger, client CiliumPodIPPoolStatusClient) *PoolStatusWriter {
+	return &PoolStatusWriter{
+		logger:    logger,
+		client:    client,
+		lastWrite: make(map[resource.Key]time.Time),
+		pending:   make(map[resource.Key]*v2alpha1.CiliumPodIPPool),
+	}
+}
+
+// Update writes pool's freshly computed status immediately, unless a write
+// for the same pool happened within minStatusWriteInterval, in which case
+// the update is coalesced and written by a later Flush.
+func (w *PoolStatusWriter) Update(ctx context.Context, pool *v2alpha1.CiliumPodIPPool) {
+	if w == nil || w.client == nil {
+		return
+	}
+
+	key := resource.Key{Name: pool.Name, Namespace: pool.Namespace}
+
+	w.mu.Lock()
+	if last, written := w.lastWrite[key]; written && time.Since(last) < minStatusWriteInterval {
+		w.pending[key] = pool
+		w.mu.Unlock()
+		return
+	}
+	w.lastWrite[key] = time.Now()
+	w.mu.Unlock()
+
+	w.write(ctx, pool)
+}
+
+// Flush writes out any pool statuses Update coalesced because they arrived
+// inside the rate-limit window, for pools whose window has since elapsed.
+// Callers reconcile-drive this (once per Reconcile pass) rather than
+// running it off a separate timer, consistent with this reconciler having
+// no background goroutines of its own.
+func (w *PoolStatusWriter) Flush(ctx context.Context) {
+	if w == nil || w.client == nil {
+		return
+	}
+
+	w.mu.Lock()
+	due := make([]*v2alpha1.CiliumPodIPPool, 0, len(w.pending))
+	for key, pool := range w.pending {
+		if time.Since(w.lastWrite[key]) < minStatusWriteInterval {
+			continue
+		}
+		due = append(due, pool)
+		delete(w.pending, key)
+		w.lastWrite[key] = time.Now()
+	}
+	w.mu.Unlock()
+
+	for _, pool := range due {
+		w.write(ctx, pool)
+	}
+}
+
+// prune drops rate-limit bookkeeping for any pool not in live, so deleted
+// pools don't leak entries in lastWrite/pending for the life of the agent.
+func (w *PoolStatusWriter) prune(live []resource.Key) {
+	if w == nil {
+		return
+	}
+
+	liveSet := make(map[resource.Key]struct{}, len(live))
+	for _, key := range live {
+		liveSet[key] = struct{}{}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key := range w.lastWrite {
+		if _, ok := liveSet[key]; !ok {
+			delete(w.lastWrite, key)
+			delete(w.pending, key)
+		}
+	}
+}
+
+func (w *PoolStatusWriter) write(ctx context.Context, pool *v2alpha1.CiliumPodIPPool) {
+	if _, err := w.client.UpdateStatus(ctx, pool, metav1.UpdateOptions{}); err != nil {
+		w.logger.Warn(
+			"failed to update CiliumPodIPPool BGP status",
+			logfields.Error, err,
+			types.PodIPPoolLogField, pool.Name,
+		)
+	}
+}
+
+// buildPoolStatus renders the Status.BGP subresource for pool from the
+// peers currently advertising it (peers), whether the pool is selected by
+// at least one advertisement (selected), whether the pool source's backing
+// store is synced (storeSynced), and the outcome of the most recent
+// reconcile attempt (reconcileErr, which may be nil). Conditions whose
+// status hasn't changed since pool's current Status.BGP keep their prior
+// LastTransitionTime, per the standard meta/v1 condition contract.
+func buildPoolStatus(pool *v2alpha1.CiliumPodIPPool, peers []PoolPeerStatus, selected, storeSynced bool, reconcileErr error) *v2alpha1.CiliumPodIPPool {
+	updated := pool.DeepCopy()
+
+	bgpPeers := make([]v2alpha1.CiliumPodIPPoolBGPPeerStatus, 0, len(peers))
+	for _, peer := range peers {
+		prefixStatus := make([]v2alpha1.CiliumPodIPPoolBGPPrefixStatus, 0, len(peer.Prefixes))
+		for afi, prefixes := range peer.Prefixes {
+			prefixStatus = append(prefixStatus, v2alpha1.CiliumPodIPPoolBGPPrefixStatus{
+				AFI:      afi,
+				Prefixes: prefixes,
+			})
+		}
+		bgpPeers = append(bgpPeers, v2alpha1.CiliumPodIPPoolBGPPeerStatus{
+			PeerName:         peer.PeerName,
+			Prefixes:         prefixStatus,
+			RoutePolicyNames: peer.RoutePolicyNames,
+		})
+	}
+
+	lastError := ""
+	if reconcileErr != nil {
+		lastError = reconcileErr.Error()
+	}
+
+	existing := pool.Status.BGP.Conditions
+	updated.Status.BGP = v2alpha1.CiliumPodIPPoolBGPStatus{
+		Peers:     bgpPeers,
+		LastError: lastError,
+		Conditions: []metav1.Condition{
+			statusCondition(existing, ConditionAdvertised, len(bgpPeers) > 0),
+			statusCondition(existing, ConditionSelected, selected),
+			statusCondition(existing, ConditionStoreSynced, storeSynced),
+		},
+	}
+
+	return updated
+}
+
+// statusCondition renders conditionType's current state, reusing its
+// LastTransitionTime from existing if the condition's status (true/false)
+// hasn't changed.
+func statusCondition(existing []metav1.Condition, conditionType string, ok bool) metav1.Condition {
+	status, reason := metav1.ConditionFalse, "NotReady"
+	if ok {
+		status, reason = metav1.ConditionTrue, "Ready"
+	}
+
+	transitionTime := metav1.Now()
+	for _, c := range existing {
+		if c.Type == conditionType && c.Status == status {
+			transitionTime = c.LastTransitionTime
+			break
+		}
+	}
+
+	return metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		LastTransitionTime: transitionTime,
+	}
+}
+
+// reportPoolStatuses publishes, for every pool known to the reconciler's
+// IPPoolSource, its BGP status subresource and a mirrored hive cell.Health
+// report, so cilium-dbg status and operator health endpoints surface
+// per-pool advertisement failures alongside the usual datapath health.
+func (r *PodIPPoolReconciler) reportPoolStatuses(ctx context.Context, p ReconcileParams, desiredPeerAdverts PeerAdvertisements, lp map[string][]netip.Prefix, reconcileErr error) {
+	if r.statusWriter == nil && r.health == nil {
+		return
+	}
+
+	pools, listErr := r.poolSource.Pools()
+	storeSynced := listErr == nil
+	if listErr != nil {
+		r.logger.Error("failed to list pools for status reporting", logfields.Error, listErr)
+	}
+
+	keys := make([]resource.Key, 0, len(pools))
+
+	for _, pool := range pools {
+		key := resource.Key{Name: pool.Name, Namespace: pool.Namespace}
+		keys = append(keys, key)
+		selected := false
+		for _, afAdverts := range desiredPeerAdverts {
+			for _, adverts := range afAdverts {
+				for _, advert := range adverts {
+					poolSelector, err := slim_metav1.LabelSelectorAsSelector(advert.Selector)
+					if err == nil && poolSelector.Matches(podIPPoolLabelSet(pool)) && podIPPoolNamespaceMatches(pool, advert) {
+						selected = true
+					}
+				}
+			}
+		}
+
+		peers := r.poolPeerStatuses(p, pool, desiredPeerAdverts, lp)
+
+		r.reportHealth(key, selected, len(peers) > 0, reconcileErr)
+
+		// Synthetic pools (e.g. ClusterPoolSource's virtual pool) have no
+		// backing CiliumPodIPPool object to write a status subresource to.
+		if r.statusWriter != nil && pool.UID != "" {
+			r.statusWriter.Update(ctx, buildPoolStatus(pool, peers, selected, storeSynced, reconcileErr))
+		}
+	}
+
+	if r.statusWriter != nil {
+		r.statusWriter.Flush(ctx)
+		r.statusWriter.prune(keys)
+	}
+	r.pruneHealthScopes(keys)
+}
+
+// poolPeerStatuses recomputes, for status-reporting purposes only, which
+// peers are currently being advertised pool and under which route
+// policies. It mirrors the matching logic in getDesiredAFPaths and
+// getPodIPPoolPolicy but keeps results keyed by peer (those functions
+// collapse per-peer results into per-family data before returning, so
+// peer-level attribution isn't available after the fact).
+func (r *PodIPPoolReconciler) poolPeerStatuses(p ReconcileParams, pool *v2alpha1.CiliumPodIPPool, desiredPeerAdverts PeerAdvertisements, lp map[string][]netip.Prefix) []PoolPeerStatus {
+	var statuses []PoolPeerStatus
+
+	for peer, afAdverts := range desiredPeerAdverts {
+		prefixesByAFI := make(map[string][]string)
+		var policyNames []string
+
+		for family, adverts := range afAdverts {
+			fam := types.ToAgentFamily(family)
+
+			for _, advert := range adverts {
+				if advert.AdvertisementType != v2.BGPCiliumPodIPPoolAdvert {
+					continue
+				}
+
+				poolSelector, err := slim_metav1.LabelSelectorAsSelector(advert.Selector)
+				if err != nil || !poolSelector.Matches(podIPPoolLabelSet(pool)) || !podIPPoolNamespaceMatches(pool, advert) {
+					continue
+				}
+
+				allocated, hasLocal := lp[pool.Name]
+				aggregate := advert.AggregateMode == v2.BGPAggregationModeSummary || advert.AggregateMode == v2.BGPAggregationModePoolCIDR
+
+				var prefixes []netip.Prefix
+				switch {
+				case aggregate && r.isAnnouncingAggregate(p, pool):
+					prefixes = aggregatePrefixes(pool)
+				case !aggregate && hasLocal:
+					prefixes = allocated
+				default:
+					continue
+				}
+
+				afi := "ipv4"
+				if fam.Afi == types.AfiIPv6 {
+					afi = "ipv6"
+				}
+				added := false
+				for _, prefix := range prefixes {
+					if fam.Afi == types.AfiIPv4 && !prefix.Addr().Is4() {
+						continue
+					}
+					if fam.Afi == types.AfiIPv6 && !prefix.Addr().Is6() {
+						continue
+					}
+					prefixesByAFI[afi] = append(prefixesByAFI[afi], prefix.String())
+					added = true
+				}
+
+				// getPodIPPoolPolicy creates no policy for a family
+				// without any prefixes.
+				if added {
+					policyNames = append(policyNames, PolicyName(peer.Name, fam.Afi.String(), advert.AdvertisementType, podIPPoolPolicyResourceName(pool)))
+				}
+			}
+		}
+
+		if len(prefixesByAFI) == 0 {
+			continue
+		}
+
+		statuses = append(statuses, PoolPeerStatus{
+			PeerName:         peer.Name,
+			Prefixes:         prefixesByAFI,
+			RoutePolicyNames: policyNames,
+		})
+	}
+
+	return statuses
+}
+
+// reportHealth mirrors a pool's advertisement state into a hive cell.Health
+// scope keyed by pool, lazily created and cached on first use.
+func (r *PodIPPoolReconciler) reportHealth(key resource.Key, selected, advertised bool, reconcileErr error) {
+	if r.health == nil {
+		return
+	}
+
+	scope, ok := r.healthScopes[key]
+	if !ok {
+		scope = r.health.NewScope(key.String())
+		r.healthScopes[key] = scope
+	}
+
+	switch {
+	case reconcileErr != nil:
+		scope.Degraded("pod IP pool BGP reconcile failed", reconcileErr)
+	case !selected:
+		scope.OK("not selected by any BGP advertisement")
+	case !advertised:
+		scope.Degraded("selected but not currently advertised to any peer", nil)
+	default:
+		scope.OK("advertised")
+	}
+}
+
+// pruneHealthScopes drops cached health scopes for pools not in live, so
+// deleted pools don't leak scopes for the life of the agent.
+func (r *PodIPPoolReconciler) pruneHealthScopes(live []resource.Key) {
+	if len(r.healthScopes) == 0 {
+		return
+	}
+
+	liveSet := make(map[resource.Key]struct{}, len(live))
+	for _, key := range live {
+		liveSet[key] = struct{}{}
+	}
+
+	for key := range r.healthScopes {
+		if _, ok := liveSet[key]; !ok {
+			delete(r.healthScopes, key)
+		}
+	}
+}
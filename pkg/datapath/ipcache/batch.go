@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/ipcache"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+)
+
+// BatchMap is an optional extension of Map that allows a backing BPF map to
+// commit multiple entries in a single syscall via BPF_MAP_UPDATE_BATCH /
+// BPF_MAP_DELETE_BATCH. Maps which do not implement this interface (or whose
+// kernel does not support the batch commands) fall back to issuing one
+// Update/Delete per entry.
+type BatchMap interface {
+	UpdateBatch(keys []bpf.MapKey, values []bpf.MapValue) error
+	DeleteBatch(keys []bpf.MapKey) error
+}
+
+// defaultBatchWindow is the maximum amount of time pending ipcache events are
+// buffered before being committed to the BPF map.
+const defaultBatchWindow = 20 * time.Millisecond
+
+// defaultBatchSize is the number of pending entries that triggers an early
+// flush, ahead of defaultBatchWindow.
+const defaultBatchSize = 256
+
+// pendingEntry is a coalesced Upsert or Delete for a single ipcache key.
+// Later events for the same key overwrite earlier ones so that only the
+// last observed value is ever committed. notify is invoked once the entry
+// has been durably applied; it is responsible for emitting the monitor
+// notification for the logical event that produced this entry.
+type pendingEntry struct {
+	modType ipcache.CacheModification
+	key     bpf.MapKey
+	value   bpf.MapValue
+	notify  func()
+}
+
+// onFailure is invoked when a pendingEntry could not be applied to the BPF
+// map by a single Update/Delete: either directly, when the map does not
+// support batching, or as the individual retry of a failed batch. notify is the entry's own notify
+// callback; it is deliberately not called by the batcher itself, so no
+// monitor event is emitted for an event that was never actually committed,
+// but it is threaded through to the retry queue the entry is handed off to
+// so a later successful retry can still emit it.
+type onFailure func(modType ipcache.CacheModification, key bpf.MapKey, value bpf.MapValue, err error, notify func())
+
+// batcher buffers ipcache BPF map mutations over a short window and commits
+// them using the kernel batch map commands, coalescing multiple updates to
+// the same key into a single operation. If the backing map does not
+// implement BatchMap, entries are applied one at a time as they would have
+// been before batching was introduced.
+type batcher struct {
+	logger *slog.Logger
+	bpfMap Map
+	onFail onFailure
+
+	window   time.Duration
+	maxBatch int
+
+	mu      lock.Mutex
+	pending map[string]*pendingEntry
+	wakeup  chan struct{}
+
+	probeOnce sync.Once
+	useBatch  bool
+}
+
+func newBatcher(logger *slog.Logger, m Map, onFail onFailure) *batcher {
+	return &batcher{
+		logger:   logger,
+		bpfMap:   m,
+		onFail:   onFail,
+		window:   defaultBatchWindow,
+		maxBatch: defaultBatchSize,
+		pending:  make(map[string]*pendingEntry),
+		wakeup:   make(chan struct{}, 1),
+	}
+}
+
+// supportsBatch determines, once, whether the backing map implements
+// BatchMap and can therefore be used for batch commits. The result is
+// memoized for the lifetime of the batcher.
+func (b *batcher) supportsBatch() bool {
+	b.probeOnce.Do(func() {
+		_, b.useBatch = b.bpfMap.(BatchMap)
+	})
+	return b.useBatch
+}
+
+// enqueue buffers a single ipcache mutation, coalescing it with any pending
+// mutation for the same key so that the last observed value wins.
+func (b *batcher) enqueue(keyStr string, modType ipcache.CacheModification, key bpf.MapKey, value bpf.MapValue, notify func()) {
+	b.mu.Lock()
+	b.pending[keyStr] = &pendingEntry{
+		modType: modType,
+		key:     key,
+		value:   value,
+		notify:  notify,
+	}
+	full := len(b.pending) >= b.maxBatch
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run is the background flush loop, started as a goroutine by
+// NewBPFMapSink and stopped when ctx is cancelled.
+func (b *batcher) run(ctx context.Context) {
+	ticker := time.NewTicker(b.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.flush()
+			return
+		case <-ticker.C:
+			b.flush()
+		case <-b.wakeup:
+			b.flush()
+		}
+	}
+}
+
+func (b *batcher) flush() {
+	b.mu.Lock()
+	if len(b.pending) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	entries := b.pending
+	b.pending = make(map[string]*pendingEntry)
+	b.mu.Unlock()
+
+	if b.supportsBatch() {
+		b.flushBatch(entries)
+		return
+	}
+
+	for _, e := range entries {
+		b.applySingle(e)
+	}
+}
+
+// flushBatch commits the coalesced entries via the kernel batch commands,
+// split into an upsert batch and a delete batch. If a batch as a whole
+// fails, every entry it contains is retried individually so that a single
+// bad key cannot poison the rest of the batch.
+func (b *batcher) flushBatch(entries map[string]*pendingEntry) {
+	bm := b.bpfMap.(BatchMap)
+
+	var upsertKeys []bpf.MapKey
+	var upsertValues []bpf.MapValue
+	var upsertEntries []*pendingEntry
+	var deleteKeys []bpf.MapKey
+	var deleteEntries []*pendingEntry
+
+	for _, e := range entries {
+		switch e.modType {
+		case ipcache.Upsert:
+			upsertKeys = append(upsertKeys, e.key)
+			upsertValues = append(upsertValues, e.value)
+			upsertEntries = append(upsertEntries, e)
+		case ipcache.Delete:
+			deleteKeys = append(deleteKeys, e.key)
+			deleteEntries = append(deleteEntries, e)
+		}
+	}
+
+	if len(upsertKeys) > 0 {
+		if err := bm.UpdateBatch(upsertKeys, upsertValues); err != nil {
+			b.logger.Warn("batch update failed, retrying entries individually", logfields.Error, err)
+			for _, e := range upsertEntries {
+				b.applySingle(e)
+			}
+		} else {
+			for _, e := range upsertEntries {
+				e.notify()
+			}
+		}
+	}
+
+	if len(deleteKeys) > 0 {
+		if err := bm.DeleteBatch(deleteKeys); err != nil {
+			b.logger.Warn("batch delete failed, retrying entries individually", logfields.Error, err)
+			for _, e := range deleteEntries {
+				b.applySingle(e)
+			}
+		} else {
+			for _, e := range deleteEntries {
+				e.notify()
+			}
+		}
+	}
+}
+
+// applySingle applies a single entry directly to the BPF map, used both as
+// the fallback path when the map does not support batching and to retry
+// entries from a batch that failed as a whole.
+func (b *batcher) applySingle(e *pendingEntry) {
+	var err error
+	switch e.modType {
+	case ipcache.Upsert:
+		err = b.bpfMap.Update(e.key, e.value)
+	case ipcache.Delete:
+		err = b.bpfMap.Delete(e.key)
+	}
+
+	if err != nil {
+		b.onFail(e.modType, e.key, e.value, err, e.notify)
+		return
+	}
+	e.notify()
+}
@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/datapath/tunnel"
+	"github.com/cilium/cilium/pkg/ipcache"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	ipcacheMap "github.com/cilium/cilium/pkg/maps/ipcache"
+	"github.com/cilium/cilium/pkg/node"
+)
+
+// Map is the subset of a BPF map's operations a BPFMapSink needs to push
+// IPCache entries into the datapath.
+type Map interface {
+	Update(key bpf.MapKey, value bpf.MapValue) error
+	Delete(key bpf.MapKey) error
+}
+
+// BPFMapSink writes IPCache entries into the datapath's BPF ipcache map. It
+// is the default sink wired up by NewListener, and the one sink every
+// deployment prior to the introduction of IPCacheSink had.
+type BPFMapSink struct {
+	logger *slog.Logger
+	bpfMap Map
+
+	tunnelConf    tunnel.Config
+	nodeIDs       *nodeIDTracker
+	monitorNotify monitorNotify
+
+	// batcher, when non-nil, buffers Upsert/Delete events and commits
+	// them using the kernel batch map commands instead of applying them
+	// one at a time. See BPFMapSinkOption WithBatching.
+	batcher *batcher
+
+	retry *retryQueue
+}
+
+// BPFMapSinkOption configures optional BPFMapSink behavior.
+type BPFMapSinkOption func(*BPFMapSink)
+
+// WithBatching enables buffering of Upsert/Delete events into BPF_MAP_UPDATE_BATCH
+// / BPF_MAP_DELETE_BATCH commits, falling back to per-entry operations when
+// the backing map does not support batching. Intended for use during
+// large-scale churn (node join/leave, large CIDR policy reloads,
+// clustermesh resync) where per-entry updates dominate agent CPU.
+func WithBatching() BPFMapSinkOption {
+	return func(s *BPFMapSink) {
+		s.batcher = newBatcher(s.logger, s.bpfMap, s.onBatchFailure)
+	}
+}
+
+// NewBPFMapSink returns a sink that writes IPCache entries into m. Its
+// background retry goroutine (and, if WithBatching is passed, its flush
+// goroutine) run until ctx is cancelled, typically tied to a hive lifecycle
+// stop hook. nodeIDHandler may be nil, in which case every entry is written
+// with node ID 0. mn may be nil; when set, it receives a terminal "giving
+// up" monitor event for entries that exceed the retry queue's attempt
+// limit, so operators can detect silent datapath drift. Without batching,
+// per-event monitor notifications are the separate MonitorSink's job; with
+// WithBatching, this sink sends them to mn itself once each commit succeeds.
+func NewBPFMapSink(ctx context.Context, m Map, mn monitorNotify, tunnelConf tunnel.Config, nodeIDHandler NodeIDHandler, logger *slog.Logger, opts ...BPFMapSinkOption) *BPFMapSink {
+	s := &BPFMapSink{
+		logger:        logger,
+		bpfMap:        m,
+		tunnelConf:    tunnelConf,
+		nodeIDs:       newNodeIDTracker(nodeIDHandler),
+		monitorNotify: mn,
+	}
+	s.retry = newRetryQueue(logger, m, mn)
+	go s.retry.run(ctx)
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.batcher != nil {
+		go s.batcher.run(ctx)
+	}
+
+	return s
+}
+
+// notifyFunc returns the batcher notify callback for event: a closure that
+// emits the monitor notification for this logical event, called only once
+// the batch commit that carries it actually succeeds. This keeps the
+// batch-commit ordering guarantee intact - unlike a separately configured
+// MonitorSink, which would fire synchronously and ahead of the commit - by
+// routing the notification through the same per-entry completion signal the
+// batcher already uses to gate retries.
+func (s *BPFMapSink) notifyFunc(event IPCacheEvent) func() {
+	if s.monitorNotify == nil {
+		return func() {}
+	}
+	typ, msg := monitorMessageForEvent(event)
+	if msg == nil {
+		return func() {}
+	}
+	return func() {
+		if err := s.monitorNotify.SendEvent(typ, msg); err != nil {
+			s.logger.Warn("failed to send ipcache monitor notification", logfields.Error, err)
+		}
+	}
+}
+
+// combineNotify returns a function that calls each non-nil fn in fns. Used
+// to combine a batcher/retry completion callback out of independent
+// concerns (monitor notification, node ID release) that must each only
+// fire once a write is confirmed committed.
+func combineNotify(fns ...func()) func() {
+	return func() {
+		for _, fn := range fns {
+			if fn != nil {
+				fn()
+			}
+		}
+	}
+}
+
+// onBatchFailure is called by the batcher when a pending entry could not be
+// applied to the BPF map by a single Update/Delete. It is handed off
+// to the retry queue, along with its notify callback, so the datapath
+// doesn't silently drift out of sync and the entry's monitor notification
+// is still emitted once a later retry actually commits it.
+func (s *BPFMapSink) onBatchFailure(modType ipcache.CacheModification, key bpf.MapKey, value bpf.MapValue, err error, notify func()) {
+	s.logger.Warn(
+		"unable to apply bpf map entry, queueing for retry",
+		logfields.Error, err,
+		logfields.Key, key,
+	)
+	s.retry.enqueue(key.String(), modType, key, value, notify)
+}
+
+// TODO (FIXME): GH-3161.
+//
+// 'OldIdentity' is ignored here, because in the BPF maps an update for the
+// IP->ID mapping will replace any existing contents; knowledge of the old
+// pair is not required to upsert the new pair.
+func (s *BPFMapSink) Apply(event IPCacheEvent) error {
+	cidr := event.CIDR
+	key := ipcacheMap.NewKey(cidr.IP, cidr.Mask, uint16(event.CIDRCluster.ClusterID()))
+
+	switch event.ModType {
+	case ipcache.Upsert:
+		var tunnelEndpoint net.IP
+		isLocal := false
+		if event.NewHostIP != nil {
+			// If the hostIP is specified and it doesn't point to
+			// the local host, then the ipcache should be populated
+			// with the hostIP so that this traffic can be guided
+			// to a tunnel endpoint destination.
+			switch s.tunnelConf.UnderlayProtocol() {
+			case tunnel.IPv4:
+				nodeIPv4 := node.GetIPv4()
+				if ip4 := event.NewHostIP.To4(); ip4 != nil && !ip4.Equal(nodeIPv4) {
+					tunnelEndpoint = ip4
+				} else {
+					isLocal = true
+				}
+			case tunnel.IPv6:
+				nodeIPv6 := node.GetIPv6()
+				if !event.NewHostIP.Equal(nodeIPv6) {
+					tunnelEndpoint = event.NewHostIP
+				} else {
+					isLocal = true
+				}
+			}
+		}
+
+		nodeID, releaseOld := s.nodeIDs.resolve(key.String(), event.NewHostIP, isLocal)
+		value := ipcacheMap.NewValue(uint32(event.NewIdentity.ID), tunnelEndpoint, nodeID, event.EncryptKey,
+			ipcacheMap.RemoteEndpointInfoFlags(event.EndpointFlags))
+
+		if s.batcher != nil {
+			s.batcher.enqueue(key.String(), event.ModType, &key, &value, combineNotify(s.notifyFunc(event), releaseOld))
+			return nil
+		}
+
+		if err := s.bpfMap.Update(&key, &value); err != nil {
+			s.logger.Warn("unable to update bpf map, queueing for retry",
+				logfields.Error, err,
+				logfields.Key, key,
+				logfields.Value, value,
+			)
+			s.retry.enqueue(key.String(), event.ModType, &key, &value, releaseOld)
+			return err
+		}
+		if releaseOld != nil {
+			releaseOld()
+		}
+		return nil
+
+	case ipcache.Delete:
+		release := s.nodeIDs.forget(key.String())
+
+		if s.batcher != nil {
+			s.batcher.enqueue(key.String(), event.ModType, &key, nil, combineNotify(s.notifyFunc(event), release))
+			return nil
+		}
+
+		if err := s.bpfMap.Delete(&key); err != nil {
+			s.logger.Warn("unable to delete from bpf map, queueing for retry",
+				logfields.Error, err,
+				logfields.Key, key,
+			)
+			s.retry.enqueue(key.String(), event.ModType, &key, nil, release)
+			return err
+		}
+		if release != nil {
+			release()
+		}
+		return nil
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+// FileSink writes every applied IPCacheEvent as a newline-delimited JSON
+// object to w. It is intended for offline inspection or export to a
+// platform without eBPF map pinning, not as a source of truth the agent
+// reads back from.
+type FileSink struct {
+	mu lock.Mutex
+	w  io.Writer
+}
+
+// NewFileSink returns a sink that appends every event to w as NDJSON.
+func NewFileSink(w io.Writer) *FileSink {
+	return &FileSink{w: w}
+}
+
+func (s *FileSink) Apply(event IPCacheEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
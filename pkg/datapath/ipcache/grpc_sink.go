@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"errors"
+
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+// IPCacheEventStream is the narrow interface a gRPC server stream (or any
+// other push-based transport) must implement to receive forwarded ipcache
+// events. It matches the single method generated for a server-streaming
+// RPC's send side, so a generated gRPC stream type satisfies it directly.
+type IPCacheEventStream interface {
+	Send(event *IPCacheEvent) error
+}
+
+// GRPCStreamSink forwards every applied IPCacheEvent to zero or more
+// subscribed streams, e.g. so that an external process (a sidecar, a
+// chained CNI plugin) can observe the ipcache without needing eBPF map
+// access itself. A subscriber that errors is dropped so one broken
+// consumer cannot wedge the others.
+type GRPCStreamSink struct {
+	mu      lock.RWMutex
+	streams map[string]IPCacheEventStream
+}
+
+// NewGRPCStreamSink returns an empty GRPCStreamSink ready to accept
+// subscribers.
+func NewGRPCStreamSink() *GRPCStreamSink {
+	return &GRPCStreamSink{
+		streams: make(map[string]IPCacheEventStream),
+	}
+}
+
+// Subscribe registers stream under id, replacing any existing subscriber
+// with the same id.
+func (s *GRPCStreamSink) Subscribe(id string, stream IPCacheEventStream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[id] = stream
+}
+
+// Unsubscribe removes the subscriber registered under id, if any.
+func (s *GRPCStreamSink) Unsubscribe(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, id)
+}
+
+func (s *GRPCStreamSink) Apply(event IPCacheEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs error
+	for id, stream := range s.streams {
+		if err := stream.Send(&event); err != nil {
+			errs = errors.Join(errs, err)
+			delete(s.streams, id)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/cilium/pkg/ipcache"
+)
+
+// erroringSink always fails, to verify that one failing sink does not
+// prevent the others configured on a BPFListener from being applied.
+type erroringSink struct{}
+
+func (erroringSink) Apply(IPCacheEvent) error {
+	return errors.New("boom")
+}
+
+func TestBPFListenerFansOutToAllSinks(t *testing.T) {
+	recorder := NewRecordingSink()
+	l := NewSinkListener(slog.Default(), erroringSink{}, recorder)
+
+	event := IPCacheEvent{
+		ModType:     ipcache.Upsert,
+		NewIdentity: ipcache.Identity{},
+	}
+	l.apply(event)
+
+	events := recorder.Events()
+	require.Len(t, events, 1)
+	require.Equal(t, ipcache.Upsert, events[0].ModType)
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"github.com/cilium/cilium/pkg/ipcache"
+	monitorAPI "github.com/cilium/cilium/pkg/monitor/api"
+)
+
+// monitorNotify is an interface to notify the monitor about ipcache changes.
+type monitorNotify interface {
+	SendEvent(typ int, event any) error
+}
+
+// MonitorSink emits a monitor notification for every applied IPCacheEvent,
+// reproducing the observability BPFListener always provided before sinks
+// were split out.
+type MonitorSink struct {
+	monitorNotify monitorNotify
+}
+
+// NewMonitorSink returns a sink that forwards every event to mn as a
+// monitor notification.
+func NewMonitorSink(mn monitorNotify) *MonitorSink {
+	return &MonitorSink{monitorNotify: mn}
+}
+
+func (s *MonitorSink) Apply(event IPCacheEvent) error {
+	if s.monitorNotify == nil {
+		return nil
+	}
+
+	typ, msg := monitorMessageForEvent(event)
+	if msg == nil {
+		return nil
+	}
+	return s.monitorNotify.SendEvent(typ, msg)
+}
+
+// monitorMessageForEvent builds the monitor notification for event, or
+// returns a nil msg if event's ModType carries no notification. Factored
+// out of MonitorSink.Apply so BPFMapSink can emit the same notification
+// itself once a batched commit actually succeeds, rather than relying on
+// MonitorSink firing independently and ahead of the commit.
+func monitorMessageForEvent(event IPCacheEvent) (typ int, msg any) {
+	var (
+		k8sNamespace, k8sPodName string
+		newIdentity, oldIdentity uint32
+		oldIdentityPtr           *uint32
+	)
+
+	if event.K8sMeta != nil {
+		k8sNamespace = event.K8sMeta.Namespace
+		k8sPodName = event.K8sMeta.PodName
+	}
+
+	newIdentity = event.NewIdentity.ID.Uint32()
+	if event.OldIdentity != nil {
+		oldIdentity = event.OldIdentity.ID.Uint32()
+		oldIdentityPtr = &oldIdentity
+	}
+
+	switch event.ModType {
+	case ipcache.Upsert:
+		return monitorAPI.MessageTypeAgent, monitorAPI.IPCacheUpsertedMessage(event.CIDR.String(), newIdentity, oldIdentityPtr,
+			event.NewHostIP, event.OldHostIP, event.EncryptKey, k8sNamespace, k8sPodName)
+	case ipcache.Delete:
+		return monitorAPI.MessageTypeAgent, monitorAPI.IPCacheDeletedMessage(event.CIDR.String(), newIdentity, oldIdentityPtr,
+			event.NewHostIP, event.OldHostIP, event.EncryptKey, k8sNamespace, k8sPodName)
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"net"
+
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+// NodeIDHandler resolves the host IP carried by an ipcache entry to the
+// compact 16-bit node ID the datapath uses to key on a remote node without
+// needing the full tunnel IP. It mirrors the allocator used by the operator
+// (see AllocateNodeID) so that the agent and operator agree on the mapping.
+type NodeIDHandler interface {
+	// AllocateNodeID allocates (or returns the existing) node ID for
+	// nodeIP.
+	AllocateNodeID(nodeIP net.IP) uint16
+
+	// DeallocateNodeID releases a node ID that is no longer referenced by
+	// any ipcache entry.
+	DeallocateNodeID(nodeID uint16)
+}
+
+// nodeIDTracker refcounts the (prefix, nodeID) pairs a BPFListener has
+// installed into the BPF map, so that a node ID can be released via
+// NodeIDHandler.DeallocateNodeID once the last prefix referring to its host
+// IP has been removed.
+type nodeIDTracker struct {
+	handler NodeIDHandler
+
+	mu lock.Mutex
+	// prefixNodeID records the node ID that was last written for a given
+	// ipcache key string, so Delete can look it up without needing the
+	// host IP again.
+	prefixNodeID map[string]uint16
+	// refs counts how many prefixes currently reference a given node ID.
+	refs map[uint16]int
+}
+
+func newNodeIDTracker(handler NodeIDHandler) *nodeIDTracker {
+	return &nodeIDTracker{
+		handler:      handler,
+		prefixNodeID: make(map[string]uint16),
+		refs:         make(map[uint16]int),
+	}
+}
+
+// resolve returns the node ID to write for newHostIP, allocating one if
+// necessary, and records the (keyStr, nodeID) association for later
+// release. A nil or local newHostIP resolves to node ID 0, which is never
+// tracked or released.
+//
+// If keyStr previously referenced a different node ID, that old node ID is
+// dropped from the tracker here, but releaseOld - the actual call into
+// handler.DeallocateNodeID - is returned rather than invoked immediately.
+// The caller must only invoke releaseOld once the write carrying the new
+// node ID has been confirmed committed to the BPF map; calling it any
+// earlier risks the old node ID being reallocated to a different node
+// while the map still holds an entry (however briefly, if the commit
+// fails) keyed on it. releaseOld is nil if there is nothing to release.
+func (t *nodeIDTracker) resolve(keyStr string, newHostIP net.IP, isLocal bool) (nodeID uint16, releaseOld func()) {
+	if t == nil || t.handler == nil {
+		return 0, nil
+	}
+	if newHostIP == nil || isLocal {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if old, exists := t.prefixNodeID[keyStr]; exists {
+			delete(t.prefixNodeID, keyStr)
+			return 0, t.release(old)
+		}
+		return 0, nil
+	}
+
+	nodeID = t.handler.AllocateNodeID(newHostIP)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if old, exists := t.prefixNodeID[keyStr]; exists {
+		if old == nodeID {
+			// keyStr already references nodeID; nothing changed.
+			return nodeID, nil
+		}
+		releaseOld = t.release(old)
+	}
+	t.prefixNodeID[keyStr] = nodeID
+	t.refs[nodeID]++
+
+	return nodeID, releaseOld
+}
+
+// release drops the refcount for nodeID and, if it was the last reference,
+// returns a function that deallocates it. The deallocation itself is
+// deferred to the returned function rather than performed here so callers
+// can gate it on a write being confirmed committed. Returns nil if nodeID
+// is still referenced, or is 0. Must be called with t.mu held.
+func (t *nodeIDTracker) release(nodeID uint16) func() {
+	if nodeID == 0 {
+		return nil
+	}
+
+	t.refs[nodeID]--
+	if t.refs[nodeID] > 0 {
+		return nil
+	}
+	delete(t.refs, nodeID)
+	return func() { t.handler.DeallocateNodeID(nodeID) }
+}
+
+// forget drops the bookkeeping for keyStr on ipcache.Delete and returns a
+// function that releases the node ID it referenced, if it was the last
+// prefix referencing it. As with resolve's releaseOld, the caller must only
+// invoke the returned function once the delete has been confirmed
+// committed to the BPF map. Returns nil if keyStr was not tracked or there
+// is nothing to release.
+func (t *nodeIDTracker) forget(keyStr string) func() {
+	if t == nil || t.handler == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	nodeID, exists := t.prefixNodeID[keyStr]
+	if !exists {
+		return nil
+	}
+	delete(t.prefixNodeID, keyStr)
+	return t.release(nodeID)
+}
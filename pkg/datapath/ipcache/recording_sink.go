@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"slices"
+
+	"github.com/cilium/cilium/pkg/lock"
+)
+
+// RecordingSink is an IPCacheSink that just records every event it is
+// given, for use in tests asserting on what BPFListener produced without
+// needing a real or mock BPF map.
+type RecordingSink struct {
+	mu     lock.Mutex
+	events []IPCacheEvent
+}
+
+// NewRecordingSink returns an empty RecordingSink.
+func NewRecordingSink() *RecordingSink {
+	return &RecordingSink{}
+}
+
+func (s *RecordingSink) Apply(event IPCacheEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Events returns a snapshot of every event recorded so far, in application
+// order.
+func (s *RecordingSink) Events() []IPCacheEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.events)
+}
+
+// Reset discards all recorded events.
+func (s *RecordingSink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+}
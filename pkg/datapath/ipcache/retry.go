@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/ipcache"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/logging/logfields"
+	"github.com/cilium/cilium/pkg/metrics"
+	monitorAPI "github.com/cilium/cilium/pkg/monitor/api"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry of a failed
+	// ipcache BPF map operation.
+	retryBaseDelay = 250 * time.Millisecond
+
+	// retryMaxDelay caps the exponential backoff applied between retries.
+	retryMaxDelay = 30 * time.Second
+
+	// retryMaxAttempts is the number of attempts made before an entry is
+	// given up on and a terminal monitor event is emitted.
+	retryMaxAttempts = 10
+
+	// retryTickInterval is how often the retry queue is scanned for
+	// entries whose next-attempt time has elapsed.
+	retryTickInterval = 250 * time.Millisecond
+)
+
+// retryEntry is a single BPF map operation pending retry.
+type retryEntry struct {
+	modType     ipcache.CacheModification
+	key         bpf.MapKey
+	value       bpf.MapValue
+	attempts    int
+	nextAttempt time.Time
+	// notify, if non-nil, is called once this entry is successfully
+	// committed by a retry, so the logical event it represents still gets
+	// its per-event monitor notification despite needing a retry.
+	notify func()
+}
+
+// retryQueue is a bounded in-memory queue of failed ipcache BPF map
+// operations. Entries are retried with exponential backoff; later events for
+// the same key collapse onto the pending entry so the queue never grows
+// unbounded under sustained churn. After retryMaxAttempts, an entry is
+// dropped and a terminal monitor event is emitted so operators can detect
+// silent datapath drift.
+type retryQueue struct {
+	logger        *slog.Logger
+	bpfMap        Map
+	monitorNotify monitorNotify
+
+	mu      lock.Mutex
+	entries map[string]*retryEntry
+}
+
+func newRetryQueue(logger *slog.Logger, m Map, mn monitorNotify) *retryQueue {
+	return &retryQueue{
+		logger:        logger,
+		bpfMap:        m,
+		monitorNotify: mn,
+		entries:       make(map[string]*retryEntry),
+	}
+}
+
+// enqueue records a failed operation for retry, collapsing it onto any
+// already-pending entry for the same key so that only the latest value is
+// ever retried. notify, if non-nil, is called once the entry is eventually
+// committed by a retry.
+//
+// attempts is deliberately carried over by a coalesced update: a key that
+// keeps failing under sustained churn must still reach retryMaxAttempts and
+// emit the terminal "giving up" monitor event, rather than having every new
+// event for that key reset its attempt count back to zero. A coalesced
+// update installs a fresh entry rather than mutating the pending one, so a
+// retryOne already in flight for the old value sees that it was replaced.
+func (q *retryQueue) enqueue(keyStr string, modType ipcache.CacheModification, key bpf.MapKey, value bpf.MapValue, notify func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := &retryEntry{
+		modType:     modType,
+		key:         key,
+		value:       value,
+		notify:      notify,
+		nextAttempt: time.Now().Add(retryBaseDelay),
+	}
+	if prev, exists := q.entries[keyStr]; exists {
+		e.attempts = prev.attempts
+	} else {
+		metrics.IPCacheErrorsTotal.WithLabelValues(string(modType), "queued").Inc()
+	}
+	q.entries[keyStr] = e
+
+	metrics.IPCacheRetryQueueLen.Set(float64(len(q.entries)))
+}
+
+// run periodically re-applies due entries until ctx is cancelled.
+func (q *retryQueue) run(ctx context.Context) {
+	ticker := time.NewTicker(retryTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.retryDue()
+		}
+	}
+}
+
+func (q *retryQueue) retryDue() {
+	now := time.Now()
+
+	q.mu.Lock()
+	due := make([]string, 0)
+	for keyStr, e := range q.entries {
+		if !e.nextAttempt.After(now) {
+			due = append(due, keyStr)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, keyStr := range due {
+		q.retryOne(keyStr)
+	}
+}
+
+func (q *retryQueue) retryOne(keyStr string) {
+	q.mu.Lock()
+	e, exists := q.entries[keyStr]
+	if !exists {
+		q.mu.Unlock()
+		return
+	}
+	q.mu.Unlock()
+
+	var err error
+	switch e.modType {
+	case ipcache.Upsert:
+		err = q.bpfMap.Update(e.key, e.value)
+	case ipcache.Delete:
+		err = q.bpfMap.Delete(e.key)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// The entry may have been replaced (or removed) by a newer event
+	// while the retry was in flight; only act on it if it is still the
+	// same one we just retried.
+	if cur, exists := q.entries[keyStr]; !exists || cur != e {
+		return
+	}
+
+	if err == nil {
+		delete(q.entries, keyStr)
+		metrics.IPCacheRetryQueueLen.Set(float64(len(q.entries)))
+		metrics.IPCacheRetrySuccessTotal.WithLabelValues(string(e.modType)).Inc()
+		if e.notify != nil {
+			e.notify()
+		}
+		return
+	}
+
+	e.attempts++
+	if e.attempts >= retryMaxAttempts {
+		delete(q.entries, keyStr)
+		metrics.IPCacheRetryQueueLen.Set(float64(len(q.entries)))
+		metrics.IPCacheRetryFailureTotal.WithLabelValues(string(e.modType)).Inc()
+
+		q.logger.Error(
+			"giving up on ipcache bpf map entry after repeated failures, datapath may be inconsistent",
+			logfields.Error, err,
+			logfields.Key, e.key,
+		)
+		q.notifyGivingUp(e)
+		return
+	}
+
+	delay := retryBaseDelay << e.attempts
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	e.nextAttempt = time.Now().Add(delay)
+}
+
+func (q *retryQueue) notifyGivingUp(e *retryEntry) {
+	if q.monitorNotify == nil {
+		return
+	}
+	msg := monitorAPI.AgentNotify{
+		Type: monitorAPI.AgentNotifyIPCacheEntryFailed,
+		Text: "giving up on ipcache bpf map entry after repeated failures",
+	}
+	q.monitorNotify.SendEvent(monitorAPI.MessageTypeAgent, msg)
+}
+
+// depth returns the current number of entries awaiting retry.
+func (q *retryQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
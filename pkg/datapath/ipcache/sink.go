@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package ipcache
+
+import (
+	"net"
+
+	cmtypes "github.com/cilium/cilium/pkg/clustermesh/types"
+	"github.com/cilium/cilium/pkg/ipcache"
+)
+
+// IPCacheEvent is a normalized representation of a single change reported by
+// pkg/ipcache, independent of how it ends up being consumed. It is the
+// common input to every IPCacheSink.
+type IPCacheEvent struct {
+	ModType       ipcache.CacheModification
+	CIDRCluster   cmtypes.PrefixCluster
+	CIDR          net.IPNet
+	OldHostIP     net.IP
+	NewHostIP     net.IP
+	OldIdentity   *ipcache.Identity
+	NewIdentity   ipcache.Identity
+	EncryptKey    uint8
+	K8sMeta       *ipcache.K8sMetadata
+	EndpointFlags uint8
+}
+
+// IPCacheSink consumes normalized IPCache events. BPFListener fans every
+// event out to its configured sinks, so that the BPF-map write, the monitor
+// notification, and any other consumer (a gRPC stream, a file for
+// offline inspection, ...) are independent of one another. A sink that
+// fails to apply an event returns an error; BPFListener logs it and moves
+// on to the remaining sinks rather than letting one consumer's failure
+// block the others.
+type IPCacheSink interface {
+	Apply(event IPCacheEvent) error
+}
+
+func normalizeEvent(modType ipcache.CacheModification, cidrCluster cmtypes.PrefixCluster,
+	oldHostIP, newHostIP net.IP, oldID *ipcache.Identity, newID ipcache.Identity,
+	encryptKey uint8, k8sMeta *ipcache.K8sMetadata, endpointFlags uint8) IPCacheEvent {
+	return IPCacheEvent{
+		ModType:       modType,
+		CIDRCluster:   cidrCluster,
+		CIDR:          cidrCluster.AsIPNet(),
+		OldHostIP:     oldHostIP,
+		NewHostIP:     newHostIP,
+		OldIdentity:   oldID,
+		NewIdentity:   newID,
+		EncryptKey:    encryptKey,
+		K8sMeta:       k8sMeta,
+		EndpointFlags: endpointFlags,
+	}
+}
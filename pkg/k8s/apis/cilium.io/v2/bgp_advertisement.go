@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package v2
+
+import (
+	slim_metav1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
+)
+
+// BGPAdvertisementType defines the type of advertisement.
+type BGPAdvertisementType string
+
+const (
+	// BGPCiliumPodIPPoolAdvert enables the export of prefixes out of
+	// CiliumPodIPPools selected by an advertisement's Selector.
+	BGPCiliumPodIPPoolAdvert BGPAdvertisementType = "PodIPPool"
+)
+
+// BGPAggregationMode defines how prefixes originating from a CiliumPodIPPool
+// are advertised upstream.
+type BGPAggregationMode string
+
+const (
+	// BGPAggregationModeNone advertises every allocated prefix as-is,
+	// with no aggregation. This is the default.
+	BGPAggregationModeNone BGPAggregationMode = "None"
+
+	// BGPAggregationModeSummary is handled exactly like
+	// BGPAggregationModePoolCIDR: the pool's configured CIDRs are
+	// advertised from the single elected announcer. No per-node summary
+	// of a node's own allocations is computed.
+	BGPAggregationModeSummary BGPAggregationMode = "Summary"
+
+	// BGPAggregationModePoolCIDR advertises the pool's full CIDR as a
+	// single route from one node, elected among the nodes holding an
+	// allocation out of it, instead of per-node or per-prefix routes.
+	BGPAggregationModePoolCIDR BGPAggregationMode = "PoolCIDR"
+)
+
+// BGPAdvertisement defines a BGP advertisement of selected resources.
+type BGPAdvertisement struct {
+	// AdvertisementType specifies the type of advertisement.
+	AdvertisementType BGPAdvertisementType
+
+	// Selector selects the resources of AdvertisementType that should be
+	// advertised.
+	//
+	// +optional
+	Selector *slim_metav1.LabelSelector
+
+	// AggregateMode specifies how selected CiliumPodIPPool prefixes are
+	// aggregated before being advertised. Defaults to
+	// BGPAggregationModeNone.
+	//
+	// +optional
+	AggregateMode BGPAggregationMode
+
+	// Namespaces restricts a PodIPPool advertisement to CiliumPodIPPools
+	// in one of the listed namespaces. An empty list matches pools in
+	// any namespace.
+	//
+	// +optional
+	Namespaces []string
+}
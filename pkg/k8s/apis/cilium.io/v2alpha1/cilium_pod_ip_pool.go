@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package v2alpha1
+
+import (
+	"fmt"
+	"net/netip"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:categories={cilium},singular="ciliumpodippool",path="ciliumpodippools",scope="Cluster",shortName={cpip}
+//
+// CiliumPodIPPool defines an IP pool that can be used for pod IPAM and,
+// optionally, advertised over BGP. It remains cluster-scoped, as it has
+// always shipped, so that existing CiliumPodIPPool resources aren't
+// orphaned by a CRD scope change. BGPAdvertisement.Namespaces filters
+// against Spec.Namespace, a logical grouping independent of the resource's
+// own (cluster) scope, rather than the object's metadata namespace.
+type CiliumPodIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CiliumPodIPPoolSpec   `json:"spec,omitempty"`
+	Status CiliumPodIPPoolStatus `json:"status,omitempty"`
+}
+
+// CiliumPodIPPoolSpec specifies the CIDRs a CiliumPodIPPool allocates from.
+type CiliumPodIPPoolSpec struct {
+	// IPv4 describes the IPv4 allocation range and the per-node mask size
+	// carved out of it.
+	//
+	// +optional
+	IPv4 IPAMPoolSpec `json:"ipv4,omitempty"`
+
+	// IPv6 describes the IPv6 allocation range and the per-node mask size
+	// carved out of it.
+	//
+	// +optional
+	IPv6 IPAMPoolSpec `json:"ipv6,omitempty"`
+
+	// Namespace is the logical namespace this pool belongs to for the
+	// purposes of a BGPAdvertisement's Namespaces filter. It is a plain
+	// spec field, not the resource's own (cluster) scope, since
+	// CiliumPodIPPool remains cluster-scoped. Empty matches an
+	// advertisement with no Namespaces filter configured.
+	//
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// IPAMPoolSpec describes the CIDRs backing one address family of a
+// CiliumPodIPPool, and the mask size of the per-node blocks allocated from
+// them.
+type IPAMPoolSpec struct {
+	// CIDRs is the set of CIDRs backing this pool.
+	CIDRs []PoolCIDR `json:"cidrs,omitempty"`
+
+	// MaskSize is the mask size of the per-node blocks allocated out of
+	// CIDRs.
+	MaskSize uint8 `json:"maskSize,omitempty"`
+}
+
+// PoolCIDR is a CIDR string belonging to a CiliumPodIPPool.
+type PoolCIDR string
+
+// ToPrefix parses c as a netip.Prefix.
+func (c PoolCIDR) ToPrefix() (*netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(string(c))
+	if err != nil {
+		return nil, fmt.Errorf("parse pool CIDR %q: %w", string(c), err)
+	}
+	return &prefix, nil
+}
+
+// DeepCopy returns a deep copy of pool.
+func (pool *CiliumPodIPPool) DeepCopy() *CiliumPodIPPool {
+	if pool == nil {
+		return nil
+	}
+	out := new(CiliumPodIPPool)
+	*out = *pool
+	out.TypeMeta = pool.TypeMeta
+	pool.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.IPv4.CIDRs = append([]PoolCIDR(nil), pool.Spec.IPv4.CIDRs...)
+	out.Spec.IPv6.CIDRs = append([]PoolCIDR(nil), pool.Spec.IPv6.CIDRs...)
+	out.Status = *pool.Status.DeepCopy()
+	return out
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package v2alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CiliumPodIPPoolStatus is the status subresource of a CiliumPodIPPool.
+type CiliumPodIPPoolStatus struct {
+	// BGP reports the state of this pool's BGP advertisements, as last
+	// observed by bgpv1's reconciler.
+	//
+	// +optional
+	BGP CiliumPodIPPoolBGPStatus `json:"bgp,omitempty"`
+}
+
+// CiliumPodIPPoolBGPStatus reports the state of a CiliumPodIPPool's BGP
+// advertisements.
+type CiliumPodIPPoolBGPStatus struct {
+	// Peers are the peers currently advertising this pool.
+	//
+	// +optional
+	Peers []CiliumPodIPPoolBGPPeerStatus `json:"peers,omitempty"`
+
+	// LastError is the error returned by the most recent reconcile
+	// attempt, or empty if it succeeded.
+	//
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// Conditions report the Advertised, Selected and StoreSynced state of
+	// this pool, following the standard meta/v1 condition pattern.
+	//
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// CiliumPodIPPoolBGPPeerStatus reports the prefixes and route policies a
+// single peer currently holds for a pool.
+type CiliumPodIPPoolBGPPeerStatus struct {
+	// PeerName identifies the peer.
+	PeerName string `json:"peerName"`
+
+	// Prefixes are the prefixes announced to this peer, by address
+	// family.
+	//
+	// +optional
+	Prefixes []CiliumPodIPPoolBGPPrefixStatus `json:"prefixes,omitempty"`
+
+	// RoutePolicyNames are the names of the route policies elected for
+	// this peer.
+	//
+	// +optional
+	RoutePolicyNames []string `json:"routePolicyNames,omitempty"`
+}
+
+// CiliumPodIPPoolBGPPrefixStatus lists the prefixes announced for one
+// address family.
+type CiliumPodIPPoolBGPPrefixStatus struct {
+	// AFI is the address family of Prefixes ("ipv4" or "ipv6").
+	AFI string `json:"afi"`
+
+	// Prefixes are the announced prefixes.
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// DeepCopy returns a deep copy of status.
+func (status *CiliumPodIPPoolStatus) DeepCopy() *CiliumPodIPPoolStatus {
+	if status == nil {
+		return nil
+	}
+	out := new(CiliumPodIPPoolStatus)
+	for _, peer := range status.BGP.Peers {
+		peerCopy := CiliumPodIPPoolBGPPeerStatus{
+			PeerName:         peer.PeerName,
+			RoutePolicyNames: append([]string(nil), peer.RoutePolicyNames...),
+		}
+		for _, prefix := range peer.Prefixes {
+			peerCopy.Prefixes = append(peerCopy.Prefixes, CiliumPodIPPoolBGPPrefixStatus{
+				AFI:      prefix.AFI,
+				Prefixes: append([]string(nil), prefix.Prefixes...),
+			})
+		}
+		out.BGP.Peers = append(out.BGP.Peers, peerCopy)
+	}
+	out.BGP.LastError = status.BGP.LastError
+	out.BGP.Conditions = append([]metav1.Condition(nil), status.BGP.Conditions...)
+	return out
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package ipcache provides the BPF map backing the IPCache: the mapping of
+// (possibly masked) IPs to their security identity and, for remote
+// endpoints, the tunnel/encryption metadata the datapath needs to reach
+// them. Key and RemoteEndpointInfo's layouts must stay in sync with
+// `struct ipcache_key` and `struct remote_endpoint_info` in the datapath's
+// BPF headers; alignchecker verifies this at agent startup.
+package ipcache
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/cilium/cilium/pkg/bpf"
+)
+
+// MapName is the name of the IPCache BPF map.
+const MapName = "cilium_ipcache"
+
+// Key implements the bpf.MapKey interface and mirrors `struct ipcache_key`.
+// IPv4 addresses occupy the first 4 bytes of IP, with Family distinguishing
+// the two so a single map holds both address families.
+type Key struct {
+	Prefixlen uint32
+	Family    uint16
+	ClusterID uint16
+	IP        [16]byte
+}
+
+// NewKey returns a Key for ip in clusterID, with Prefixlen taken from mask.
+// ip is stored as given; bits beyond the prefix length are not cleared.
+func NewKey(ip net.IP, mask net.IPMask, clusterID uint16) Key {
+	ones, _ := mask.Size()
+	key := Key{
+		Prefixlen: uint32(ones),
+		ClusterID: clusterID,
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		key.Family = bpf.EndpointKeyIPv4
+		copy(key.IP[:4], ip4)
+	} else {
+		key.Family = bpf.EndpointKeyIPv6
+		copy(key.IP[:], ip.To16())
+	}
+
+	return key
+}
+
+func (k *Key) String() string {
+	return fmt.Sprintf("%s/%d (cluster %d)", net.IP(k.IP[:]), k.Prefixlen, k.ClusterID)
+}
+
+func (k *Key) New() bpf.MapKey { return &Key{} }
+
+// RemoteEndpointInfoFlags carries auxiliary per-entry datapath flags (e.g.
+// whether the entry identifies a remote node rather than a remote
+// endpoint).
+type RemoteEndpointInfoFlags uint8
+
+// RemoteEndpointInfo implements the bpf.MapValue interface and mirrors
+// `struct remote_endpoint_info`. It is the value half of every IPCache BPF
+// map entry.
+type RemoteEndpointInfo struct {
+	SecurityIdentity uint32
+	TunnelEndpoint   [4]byte
+	// NodeID is the compact node ID the datapath uses to key the node's
+	// encryption/tunnel state without needing the full TunnelEndpoint IP.
+	// It is 0 for entries with no associated remote node (e.g. entries
+	// resolved to the local host).
+	NodeID uint16
+	Key    uint8
+	Flags  RemoteEndpointInfoFlags
+}
+
+// NewValue returns a RemoteEndpointInfo for the given identity, optional
+// tunnel endpoint, node ID, encryption key and flags. hostIP may be nil, in
+// which case TunnelEndpoint is left zeroed (the entry resolves to a
+// directly reachable or local endpoint).
+func NewValue(identity uint32, hostIP net.IP, nodeID uint16, encryptKey uint8, flags RemoteEndpointInfoFlags) RemoteEndpointInfo {
+	info := RemoteEndpointInfo{
+		SecurityIdentity: identity,
+		NodeID:           nodeID,
+		Key:              encryptKey,
+		Flags:            flags,
+	}
+
+	if ip4 := hostIP.To4(); ip4 != nil {
+		copy(info.TunnelEndpoint[:], ip4)
+	}
+
+	return info
+}
+
+func (v *RemoteEndpointInfo) String() string {
+	return fmt.Sprintf("identity=%d tunnelEndpoint=%s nodeID=%d key=%d flags=%d",
+		v.SecurityIdentity, net.IP(v.TunnelEndpoint[:]), v.NodeID, v.Key, v.Flags)
+}
+
+func (v *RemoteEndpointInfo) New() bpf.MapValue { return &RemoteEndpointInfo{} }
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package metrics
+
+import "github.com/cilium/cilium/pkg/metrics/metric"
+
+// IPCacheErrorsTotal counts ipcache BPF map operations that failed and were
+// queued for retry, labeled by the operation ("Upsert"/"Delete") and
+// outcome ("queued"). Operators can use it to distinguish a transient blip
+// from sustained datapath write failures.
+var IPCacheErrorsTotal = metric.NewCounterVec(metric.CounterOpts{
+	Namespace: "cilium",
+	Subsystem: "ipcache",
+	Name:      "errors_total",
+	Help:      "Number of ipcache BPF map operation failures, by operation and outcome",
+}, []string{"op", "outcome"})
+
+// IPCacheRetryQueueLen reports the current depth of the ipcache BPF map
+// retry queue.
+var IPCacheRetryQueueLen = metric.NewGauge(metric.GaugeOpts{
+	Namespace: "cilium",
+	Subsystem: "ipcache",
+	Name:      "retry_queue_len",
+	Help:      "Number of ipcache BPF map operations currently awaiting retry",
+})
+
+// IPCacheRetrySuccessTotal counts ipcache BPF map operations that succeeded
+// after at least one retry, labeled by operation.
+var IPCacheRetrySuccessTotal = metric.NewCounterVec(metric.CounterOpts{
+	Namespace: "cilium",
+	Subsystem: "ipcache",
+	Name:      "retry_success_total",
+	Help:      "Number of ipcache BPF map operations that succeeded after a retry, by operation",
+}, []string{"op"})
+
+// IPCacheRetryFailureTotal counts ipcache BPF map operations given up on
+// after exhausting the retry queue's attempt budget, labeled by operation.
+var IPCacheRetryFailureTotal = metric.NewCounterVec(metric.CounterOpts{
+	Namespace: "cilium",
+	Subsystem: "ipcache",
+	Name:      "retry_failure_total",
+	Help:      "Number of ipcache BPF map operations given up on after repeated retry failures, by operation",
+}, []string{"op"})
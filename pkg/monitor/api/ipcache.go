@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package api
+
+// AgentNotification enumerates the subtype of an AgentNotify monitor event.
+type AgentNotification int
+
+// AgentNotifyIPCacheEntryFailed is emitted when an ipcache BPF map entry
+// could not be applied even after the retry queue exhausted its attempt
+// budget, so operators can detect datapath drift that would otherwise be
+// silent.
+const AgentNotifyIPCacheEntryFailed AgentNotification = iota + 1
+
+// AgentNotify is a monitor notification emitted by an agent-internal
+// subsystem, as opposed to the datapath itself.
+type AgentNotify struct {
+	Type AgentNotification
+	Text string
+}